@@ -0,0 +1,300 @@
+// Package cache contains the local cache of k6 binaries built by xk6.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	binariesDir = "k6"
+	entryFile   = "entry.json"
+	dirPerm     = 0o750
+	filePerm    = 0o600
+	exePerm     = 0o755
+)
+
+// Dir returns the root directory of the xk6 cache.
+// It is taken from the XK6_CACHE_DIR environment variable, or it is the xk6 directory
+// inside the user cache directory.
+func Dir() (string, error) {
+	if dir := os.Getenv("XK6_CACHE_DIR"); len(dir) != 0 { //nolint:forbidigo
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir() //nolint:forbidigo
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "xk6"), nil
+}
+
+// Key returns the content address of the given key material.
+// The material is serialized as JSON, so it should contain only deterministic values.
+func Key(material any) (string, error) {
+	data, err := json.Marshal(material)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Metadata describes the content of a cached k6 binary.
+type Metadata struct {
+	// K6Module is the k6 module path used for the build.
+	K6Module string `json:"k6_module,omitempty"`
+	// K6Version is the k6 version used for the build.
+	K6Version string `json:"k6_version,omitempty"`
+	// Extensions contains the extension modules included in the build.
+	Extensions []string `json:"extensions,omitempty"`
+	// Platform is the target platform of the binary.
+	Platform string `json:"platform,omitempty"`
+	// Created is the time when the binary was added to the cache.
+	Created time.Time `json:"created"`
+}
+
+// Entry is a cached k6 binary.
+type Entry struct {
+	Metadata
+
+	// Key is the content address of the entry.
+	Key string `json:"key"`
+	// Path is the path of the cached binary.
+	Path string `json:"path"`
+	// Size is the size of the binary in bytes.
+	Size int64 `json:"size"`
+	// LastUsed is the time when the binary was last used.
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Cache is a content-addressed store of k6 binaries.
+type Cache struct {
+	dir string
+}
+
+// Open returns the binary cache inside the given cache root directory.
+// If dir is empty, the default cache directory is used.
+func Open(dir string) (*Cache, error) {
+	if len(dir) == 0 {
+		var err error
+
+		dir, err = Dir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Cache{dir: filepath.Join(dir, binariesDir)}, nil
+}
+
+// Dir returns the directory of the binary cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns the path of the binary stored with the given key.
+// The last used time of the entry is updated on a hit.
+func (c *Cache) Get(key, exe string) (string, bool) {
+	path := filepath.Join(c.dir, key, exe)
+
+	info, err := os.Stat(path) //nolint:forbidigo
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+
+	now := time.Now()
+
+	if err := os.Chtimes(path, now, now); err != nil { //nolint:forbidigo
+		slog.Debug("Failed to touch cached binary", "path", path, "error", err)
+	}
+
+	return path, true
+}
+
+// Put copies the binary at src into the cache with the given key and returns its new path.
+func (c *Cache) Put(key, exe, src string, meta *Metadata) (string, error) {
+	entryDir := filepath.Join(c.dir, key)
+
+	if err := os.MkdirAll(entryDir, dirPerm); err != nil { //nolint:forbidigo
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(entryDir, entryFile), data, filePerm); err != nil { //nolint:forbidigo
+		return "", err
+	}
+
+	// The binary is copied into the cache directory first and renamed then,
+	// so concurrent readers never see a partially written file.
+	tmp, err := os.CreateTemp(c.dir, "."+exe+"-*") //nolint:forbidigo
+	if err != nil {
+		return "", err
+	}
+
+	if err := copyFile(tmp, src); err != nil {
+		_ = os.Remove(tmp.Name()) //nolint:forbidigo
+
+		return "", err
+	}
+
+	if err := os.Chmod(tmp.Name(), exePerm); err != nil { //nolint:forbidigo,gosec
+		_ = os.Remove(tmp.Name()) //nolint:forbidigo
+
+		return "", err
+	}
+
+	path := filepath.Join(entryDir, exe)
+
+	if err := os.Rename(tmp.Name(), path); err != nil { //nolint:forbidigo
+		_ = os.Remove(tmp.Name()) //nolint:forbidigo
+
+		return "", err
+	}
+
+	return path, nil
+}
+
+func copyFile(dst *os.File, src string) error {
+	in, err := os.Open(filepath.Clean(src)) //nolint:forbidigo
+	if err != nil {
+		_ = dst.Close()
+
+		return err
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if _, err := io.Copy(dst, in); err != nil {
+		_ = dst.Close()
+
+		return err
+	}
+
+	return dst.Close()
+}
+
+// List returns the cache entries, most recently used first.
+func (c *Cache) List() ([]*Entry, error) {
+	dirs, err := os.ReadDir(c.dir) //nolint:forbidigo
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(dirs))
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entry, err := c.readEntry(dir.Name())
+		if err != nil {
+			slog.Debug("Skipping invalid cache entry", "key", dir.Name(), "error", err)
+
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+func (c *Cache) readEntry(key string) (*Entry, error) {
+	entryDir := filepath.Join(c.dir, key)
+
+	data, err := os.ReadFile(filepath.Join(entryDir, entryFile)) //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{Key: key}
+
+	if err := json.Unmarshal(data, &entry.Metadata); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(entryDir) //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.Name() == entryFile || file.IsDir() {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Path = filepath.Join(entryDir, file.Name())
+		entry.Size = info.Size()
+		entry.LastUsed = info.ModTime()
+	}
+
+	if len(entry.Path) == 0 {
+		return nil, fs.ErrNotExist
+	}
+
+	return entry, nil
+}
+
+// Prune removes the entries that have not been used for longer than maxAge.
+// It returns the removed entries.
+func (c *Cache) Prune(maxAge time.Duration) ([]*Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(-maxAge)
+	removed := make([]*Entry, 0)
+
+	for _, entry := range entries {
+		if entry.LastUsed.After(deadline) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(c.dir, entry.Key)); err != nil { //nolint:forbidigo
+			return removed, err
+		}
+
+		removed = append(removed, entry)
+	}
+
+	return removed, nil
+}
+
+// Clean removes every entry from the cache.
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.dir) //nolint:forbidigo
+}
@@ -0,0 +1,141 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.k6.io/xk6/internal/cache"
+)
+
+func writeBinary(t *testing.T, content string) string {
+	t.Helper()
+
+	src := filepath.Join(t.TempDir(), "k6")
+
+	if err := os.WriteFile(src, []byte(content), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	return src
+}
+
+func TestKey_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	material := map[string]string{"k6": "v1.2.0", "os": "linux"}
+
+	k1, err := cache.Key(material)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k2, err := cache.Key(map[string]string{"os": "linux", "k6": "v1.2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k1 != k2 {
+		t.Errorf("expected equal keys, got %s and %s", k1, k2)
+	}
+
+	k3, err := cache.Key(map[string]string{"k6": "v1.3.0", "os": "linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k1 == k3 {
+		t.Error("expected different keys for different material")
+	}
+}
+
+func TestCache_PutGet(t *testing.T) {
+	t.Parallel()
+
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := store.Get("abc", "k6"); found {
+		t.Fatal("expected cache miss")
+	}
+
+	path, err := store.Put("abc", "k6", writeBinary(t, "binary"), &cache.Metadata{K6Version: "v1.2.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, found := store.Get("abc", "k6")
+	if !found {
+		t.Fatal("expected cache hit")
+	}
+
+	if got != path {
+		t.Errorf("expected path %s, got %s", path, got)
+	}
+
+	data, err := os.ReadFile(got) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "binary" {
+		t.Errorf("expected cached content 'binary', got %q", data)
+	}
+}
+
+func TestCache_ListPruneClean(t *testing.T) {
+	t.Parallel()
+
+	store, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPath, err := store.Put("old", "k6", writeBinary(t, "old"), &cache.Metadata{K6Version: "v1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Put("new", "k6", writeBinary(t, "new"), &cache.Metadata{K6Version: "v1.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(oldPath, past, past); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Key != "new" || entries[1].Key != "old" {
+		t.Fatalf("expected entries [new old], got %v", entries)
+	}
+
+	removed, err := store.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || removed[0].Key != "old" {
+		t.Errorf("expected old entry to be pruned, got %v", removed)
+	}
+
+	if err := store.Clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected empty cache, got %d entries", len(entries))
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"path/filepath"
 	"strings"
 
+	"github.com/grafana/k6foundry"
 	"github.com/spf13/cobra"
+	"github.com/szkiba/efa"
+	"go.k6.io/xk6/internal/sbom"
 	"go.k6.io/xk6/internal/sync"
 )
 
@@ -35,15 +39,44 @@ func buildCmd() *cobra.Command {
 			}
 
 			if len(args) > 0 {
-				opts.k6version = args[0]
+				if err := cmd.Flags().Set("k6-version", args[0]); err != nil {
+					return err
+				}
+			}
+
+			if err := applyManifest(cmd.Flags(), opts); err != nil {
+				return err
+			}
+
+			if len(opts.sbom) != 0 {
+				if _, err := sbom.ParseFormat(opts.sbom); err != nil {
+					return err
+				}
 			}
 
 			opts.outputChanged = cmd.Flags().Lookup("output").Changed
-			if !opts.outputChanged && opts.os == "windows" && !strings.HasSuffix(opts.output, ".exe") {
+
+			switch {
+			case len(opts.platforms) != 0 && !opts.outputChanged:
+				opts.output = defaultPlatformOutput
+			case !opts.outputChanged && opts.os == "windows" && !strings.HasSuffix(opts.output, ".exe"):
 				opts.output += ".exe"
 			}
 
-			return buildRunE(cmd.Context(), cmd.OutOrStdout(), opts)
+			ctx := opts.prerelease.context(cmd.Context())
+
+			if !opts.offline {
+				return buildRunE(ctx, cmd.OutOrStdout(), opts)
+			}
+
+			env, err := offlineEnv(opts.proxyDir)
+			if err != nil {
+				return err
+			}
+
+			return withEnv(env, func() error {
+				return buildRunE(ctx, cmd.OutOrStdout(), opts)
+			})
 		},
 		DisableAutoGenTag: true,
 	}
@@ -52,33 +85,116 @@ func buildCmd() *cobra.Command {
 
 	flags.SortFlags = false
 
-	flags.StringVarP(&opts.output, "output", "o", defaultK6Output(), "Output filename")
+	flags.StringVarP(&opts.output, "output", "o", defaultK6Output(), "Output filename (template for multi-platform builds)")
 
 	cobra.CheckErr(buildCommonFlags(flags, opts))
 
+	flags.StringSliceVar(&opts.platforms, "platform", nil, "Build for a list of target platforms (os/arch) or 'all'")
+	flags.IntVar(&opts.parallel, "parallel", 0, "Maximum number of concurrent platform builds (default: number of CPUs)")
+	flags.StringVar(&opts.lock, "lock", "", "Write the exact composition of the build to a lock file")
+	flags.StringVar(&opts.locked, "locked", "", "Build exactly the composition recorded in a lock file")
+
+	flags.StringVar(&opts.sbom, "sbom", "", "Write a software bill of materials next to the binary (cyclonedx or spdx)")
+	flags.BoolVar(&opts.offline, "offline", false, "Build without network access, using only the modules of the proxy directory")
+	flags.StringVar(&opts.proxyDir, "proxy-dir", "", "The directory of the modules downloaded by 'xk6 prefetch'")
+
+	cmd.MarkFlagsMutuallyExclusive("lock", "locked")
+	cmd.MarkFlagsRequiredTogether("offline", "proxy-dir")
+
+	env := efa.New(flags, appname, nil)
+
+	cobra.CheckErr(env.Bind("platform", "sbom", "offline", "proxy-dir"))
+
 	return cmd
 }
 
 func buildRunE(ctx context.Context, stdout io.Writer, opts *buildOptions) error {
-	info, err := buildK6(ctx, opts)
+	var locked *lockFile
+
+	if len(opts.locked) != 0 {
+		lock, err := readLockFile(opts.locked)
+		if err != nil {
+			return err
+		}
+
+		if err := applyLockFile(ctx, opts, lock); err != nil {
+			return err
+		}
+
+		locked = lock
+	}
+
+	infos, outputs, err := buildAll(ctx, opts)
 	if err != nil {
 		return err
 	}
 
-	slog.Info("Successful build", "platform", info.Platform)
+	for idx, info := range infos {
+		slog.Info("Successful build", "platform", info.Platform, "output", outputs[idx])
+
+		for _, w := range info.Warnings {
+			slog.Warn(w.Message, "platform", info.Platform)
+		}
+	}
+
+	if err := lockBuild(ctx, opts, locked, infos[0], outputs); err != nil {
+		return err
+	}
+
+	if err := writeSBOMs(ctx, opts, infos, outputs); err != nil {
+		return err
+	}
+
+	reportBuild(ctx, infos[0])
+
+	if len(opts.platforms) == 0 && !opts.outputChanged {
+		buildCompatMessage(stdout, opts.output)
+	}
+
+	return nil
+}
+
+// buildAll builds k6 for the host or the requested platforms and returns the build infos and the output filenames.
+func buildAll(ctx context.Context, opts *buildOptions) ([]*k6foundry.BuildInfo, []string, error) {
+	if len(opts.platforms) == 0 {
+		info, err := buildK6(ctx, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []*k6foundry.BuildInfo{info}, []string{opts.output}, nil
+	}
+
+	targets, err := buildTargets(opts)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for _, w := range info.Warnings {
-		slog.Warn(w.Message)
+	infos, err := buildK6Matrix(ctx, opts, targets)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	outputs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		outputs = append(outputs, target.output)
+	}
+
+	return infos, outputs, nil
+}
+
+// reportBuild logs the modules included in the build and checks for a newer k6 version.
+func reportBuild(ctx context.Context, info *k6foundry.BuildInfo) {
+	modVersions := maps.Clone(info.ModVersions)
+
 	k6modPath := info.K6ModPath
-	k6ver := info.ModVersions[k6modPath]
+	k6ver := modVersions[k6modPath]
 	if k6modPath != "" {
-		delete(info.ModVersions, k6modPath)
+		delete(modVersions, k6modPath)
 		slog.Info("added", "module", k6modPath, "version", k6ver)
 	}
 
-	for name, version := range info.ModVersions {
+	for name, version := range modVersions {
 		slog.Info("added", "module", name, "version", version)
 	}
 
@@ -92,12 +208,6 @@ func buildRunE(ctx context.Context, stdout io.Writer, opts *buildOptions) error
 	} else if err != nil {
 		slog.Warn("Failed to get latest k6 version", "error", err)
 	}
-
-	if !opts.outputChanged {
-		buildCompatMessage(stdout, opts.output)
-	}
-
-	return nil
 }
 
 const buildCompatMessageFmt = `
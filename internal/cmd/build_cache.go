@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/cache"
+	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/modfile"
+)
+
+// buildCacheKey is the material of the content address of a k6 binary built on the fly.
+type buildCacheKey struct {
+	GoVersion    string           `json:"go_version"`
+	K6Module     string           `json:"k6_module"`
+	K6Version    string           `json:"k6_version"`
+	Extensions   []cacheKeyModule `json:"extensions"`
+	Replacements []cacheKeyModule `json:"replacements"`
+	OS           string           `json:"os"`
+	Arch         string           `json:"arch"`
+	ARM          string           `json:"arm"`
+	Cgo          int              `json:"cgo"`
+	RaceDetector int              `json:"race_detector"`
+	BuildFlags   []string         `json:"build_flags"`
+}
+
+type cacheKeyModule struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// buildCacheKeyFor computes the cache key of the build described by opts.
+// The k6 module must already be resolved. Floating versions (latest, branches) are resolved
+// to canonical versions and local directories are hashed, so any change invalidates the key.
+func buildCacheKeyFor(ctx context.Context, opts *buildOptions) (string, error) {
+	goVersion, err := goToolchainVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	k6version, err := sync.ResolveVersion(ctx, opts.k6repo, opts.k6version)
+	if err != nil {
+		return "", err
+	}
+
+	key := &buildCacheKey{
+		GoVersion:    goVersion,
+		K6Module:     opts.k6repo,
+		K6Version:    k6version,
+		OS:           opts.os,
+		Arch:         opts.arch,
+		ARM:          opts.arm,
+		Cgo:          opts.cgo,
+		RaceDetector: opts.raceDetector,
+		BuildFlags:   opts.buildFlags,
+	}
+
+	for _, mod := range opts.extensions.modules {
+		kmod, err := cacheKeyModuleFor(ctx, mod, true)
+		if err != nil {
+			return "", err
+		}
+
+		key.Extensions = append(key.Extensions, kmod)
+	}
+
+	for _, mod := range opts.replacements.modules {
+		kmod, err := cacheKeyModuleFor(ctx, mod, false)
+		if err != nil {
+			return "", err
+		}
+
+		key.Replacements = append(key.Replacements, kmod)
+	}
+
+	return cache.Key(key)
+}
+
+// goToolchainVersion returns the version of the Go toolchain used for the builds (e.g. go1.24.2).
+func goToolchainVersion(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func cacheKeyModuleFor(ctx context.Context, mod k6foundry.Module, resolve bool) (cacheKeyModule, error) {
+	kmod := cacheKeyModule{Path: mod.Path, Version: mod.Version}
+
+	if len(mod.ReplacePath) != 0 {
+		if modfile.IsDirectoryPath(mod.ReplacePath) {
+			hash, err := hashDir(mod.ReplacePath)
+			if err != nil {
+				return kmod, err
+			}
+
+			kmod.Replace = mod.ReplacePath
+			kmod.Hash = hash
+
+			return kmod, nil
+		}
+
+		kmod.Replace = mod.ReplacePath
+		if len(mod.ReplaceVersion) != 0 {
+			kmod.Replace += "@" + mod.ReplaceVersion
+		}
+
+		return kmod, nil
+	}
+
+	if !resolve {
+		return kmod, nil
+	}
+
+	version, err := sync.ResolveVersion(ctx, mod.Path, mod.Version)
+	if err != nil {
+		return kmod, err
+	}
+
+	kmod.Version = version
+
+	return kmod, nil
+}
+
+// hashDir returns a hash of the files of the module in dir the go command builds from: go.mod, go.sum
+// and the source files of the packages (Go files except tests, and the assembly and cgo sources). The
+// directories ignored by the go command (testdata, vendor, names starting with . or _) and nested
+// modules are skipped, so editing the documentation or the tests does not change the hash.
+func hashDir(dir string) (string, error) {
+	hash := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != dir && skipHashDir(path, entry.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		root := filepath.Dir(path) == filepath.Clean(dir)
+
+		if !entry.Type().IsRegular() || !isBuildFile(root, entry.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		_, _ = io.WriteString(hash, filepath.ToSlash(rel)+"\x00")
+
+		file, err := os.Open(filepath.Clean(path)) //nolint:forbidigo
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = file.Close()
+		}()
+
+		_, err = io.Copy(hash, file)
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// skipHashDir returns true if the directory is ignored by the go command or it is a nested module.
+func skipHashDir(path, name string) bool {
+	if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(path, "go.mod")) //nolint:forbidigo
+
+	return err == nil
+}
+
+// isBuildFile returns true if the file is used by the go command to build the packages of the module.
+// The go.mod and go.sum files are only used in the root directory of the module.
+func isBuildFile(root bool, name string) bool {
+	if root && (name == "go.mod" || name == "go.sum") {
+		return true
+	}
+
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+
+	switch filepath.Ext(name) {
+	case ".go", ".s", ".S", ".sx", ".c", ".cc", ".cpp", ".cxx", ".h", ".hh", ".hpp", ".hxx", ".m", ".f", ".F",
+		".for", ".f90", ".swig", ".swigcxx", ".syso":
+		return true
+	default:
+		return false
+	}
+}
+
+func buildCacheMetadata(opts *buildOptions) *cache.Metadata {
+	meta := &cache.Metadata{
+		K6Module:  opts.k6repo,
+		K6Version: opts.k6version,
+		Platform:  opts.os + "/" + opts.arch,
+		Created:   time.Now(),
+	}
+
+	for _, mod := range opts.extensions.modules {
+		meta.Extensions = append(meta.Extensions, mod.String())
+	}
+
+	return meta
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6foundry"
+	"golang.org/x/mod/modfile"
+)
+
+func TestHashDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		t.Helper()
+
+		filename := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filename, []byte(content), 0o600); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+	}
+
+	write("go.mod", "module example.com/xk6-foo\n")
+	write("foo.go", "package foo\n")
+
+	h1, err := hashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files and directories the go command does not build from
+	write(".git/HEAD", "ref: refs/heads/main\n")
+	write("README.md", "# xk6-foo\n")
+	write("foo_test.go", "package foo\n")
+	write("testdata/script.js", "export default function () {}\n")
+	write("vendor/modules.txt", "# example.com/bar v1.0.0\n")
+	write("_examples/main.go", "package main\n")
+	write("examples/go.mod", "module example.com/examples\n")
+	write("examples/main.go", "package main\n")
+
+	h2, err := hashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h1 != h2 {
+		t.Error("expected the files not built by the go command to be ignored")
+	}
+
+	write("foo.go", "package foo\n\nconst bar = 1\n")
+
+	h3, err := hashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h1 == h3 {
+		t.Error("expected hash to change after modifying a source file")
+	}
+}
+
+func TestModfileReplacement(t *testing.T) {
+	t.Parallel()
+
+	mf, err := modfile.Parse("go.mod", []byte("module example.com/xk6-foo\n\n"+
+		"replace example.com/bar => ../bar\n\nreplace example.com/baz v1.0.0 => example.com/qux v1.1.0\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moddir := filepath.Join("src", "xk6-foo")
+
+	// relative to the directory of go.mod, not to the working directory
+	if mod := modfileReplacement(mf.Replace[0], moddir); mod.ReplacePath != filepath.Join("src", "bar") {
+		t.Errorf("unexpected replacement: %+v", mod)
+	}
+
+	expected := k6foundry.Module{Path: "example.com/baz", Version: "v1.0.0", ReplacePath: "example.com/qux", ReplaceVersion: "v1.1.0"}
+	if mod := modfileReplacement(mf.Replace[1], moddir); mod != expected {
+		t.Errorf("expected %+v, got %+v", expected, mod)
+	}
+}
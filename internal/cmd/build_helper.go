@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -13,10 +16,13 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/szkiba/efa"
 	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 type buildOptions struct {
+	config       string
 	output       string
 	extensions   *modules
 	replacements *modules
@@ -29,6 +35,17 @@ type buildOptions struct {
 	raceDetector int
 	cgo          int
 	buildFlags   []string
+	platforms    []string
+	parallel     int
+	noCache      bool
+	lock         string
+	locked       string
+	sbom         string
+	offline      bool
+	proxyDir     string
+	prerelease   prereleasePolicy
+	// pins are the module versions pinned by a lock file, passed to the build as replacements.
+	pins []k6foundry.Module
 
 	outputChanged bool
 }
@@ -81,7 +98,8 @@ func defaultK6Output() string {
 }
 
 func buildCommonFlags(flags *pflag.FlagSet, opts *buildOptions) error {
-	flags.Var(opts.extensions, "with", "Add one or more k6 extensions with Go module path")
+	flags.StringVar(&opts.config, "config", "", "Read build settings from a manifest file (e.g. xk6.yaml)")
+	flags.Var(opts.extensions, "with", "Add one or more k6 extensions with Go module path or local directory")
 	flags.Var(opts.replacements, "replace", "Replace one or more Go modules")
 	flags.StringVarP(&opts.k6version, "k6-version", "k", defaultK6Version, "The k6 version to use for build")
 	flags.StringVar(&opts.k6repo, "k6-repo", defaultK6Repo, "The k6 repository to use for the build")
@@ -93,13 +111,17 @@ func buildCommonFlags(flags *pflag.FlagSet, opts *buildOptions) error {
 	flags.IntVar(&opts.cgo, "cgo", defaultCgo, "Enable/disable cgo")
 	flags.StringArrayVar(&opts.buildFlags, "build-flags", strings.Split(defaultBuildFlags, ","), "Specify Go build flags")
 
+	if err := prereleaseFlag(flags, &opts.prerelease); err != nil {
+		return err
+	}
+
 	flags.Lookup("cgo").NoOptDefVal = "1"
 	flags.Lookup("skip-cleanup").NoOptDefVal = "1"
 	flags.Lookup("race-detector").NoOptDefVal = "1"
 
 	env := efa.New(flags, appname, nil)
 
-	err := env.Bind("k6-repo", "build-flags", "race-detector", "skip-cleanup")
+	err := env.Bind("config", "k6-repo", "build-flags", "race-detector", "skip-cleanup")
 	if err != nil {
 		return err
 	}
@@ -119,6 +141,15 @@ func buildCommonFlags(flags *pflag.FlagSet, opts *buildOptions) error {
 	return env.BindTo("cgo", "CGO_ENABLED")
 }
 
+// onTheFlyFlags defines the flags of the commands building k6 on the fly (run, x, test).
+func onTheFlyFlags(flags *pflag.FlagSet, opts *buildOptions) error {
+	flags.BoolVar(&opts.noCache, "no-cache", false, "Always build k6, do not use the binary cache")
+
+	env := efa.New(flags, appname, nil)
+
+	return env.Bind("no-cache")
+}
+
 // copyNonGoEnv copies non-Go environment variables that might be needed for the build.
 func copyNonGoEnv(env map[string]string) {
 	for _, key := range nonGoEnvToCopy {
@@ -144,22 +175,10 @@ func newFoundry(ctx context.Context, opts *buildOptions) (k6foundry.Foundry, err
 	// copy non-Go environment variables that might be needed for the build
 	copyNonGoEnv(env)
 
-	if opts.raceDetector != 0 {
-		opts.buildFlags = append(opts.buildFlags, "-race")
-	}
-
-	if opts.cgo == 0 {
-		raceDetector := false
-
-		for _, f := range opts.buildFlags {
-			raceDetector = raceDetector || strings.Contains(f, "-race")
-		}
-
-		if raceDetector && opts.cgo == 0 {
-			slog.Warn("Enabling cgo because it is required by the race detector")
+	if opts.cgo == 0 && cgoEnabled(opts) {
+		slog.Warn("Enabling cgo because it is required by the race detector")
 
-			env["CGO_ENABLED"] = "1"
-		}
+		env["CGO_ENABLED"] = "1"
 	}
 
 	fopts := k6foundry.NativeFoundryOpts{
@@ -193,11 +212,42 @@ func newFoundry(ctx context.Context, opts *buildOptions) (k6foundry.Foundry, err
 	return k6foundry.NewNativeFoundry(ctx, fopts)
 }
 
+// goBuildFlags returns the Go build flags of the build, including -race if the race detector is enabled.
+func goBuildFlags(opts *buildOptions) []string {
+	flags := slices.Clone(opts.buildFlags)
+
+	if opts.raceDetector != 0 && !slices.Contains(flags, "-race") {
+		flags = append(flags, "-race")
+	}
+
+	return flags
+}
+
+// cgoEnabled returns true if cgo is enabled, either explicitly or because the race detector requires it.
+func cgoEnabled(opts *buildOptions) bool {
+	if opts.cgo != 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(goBuildFlags(opts), func(flag string) bool { return strings.Contains(flag, "-race") })
+}
+
 func buildK6(ctx context.Context, opts *buildOptions) (*k6foundry.BuildInfo, error) {
+	if err := resolveVersions(ctx, opts); err != nil {
+		return nil, err
+	}
+
 	// When using the default k6 repo, resolve the correct module path so that
 	// v2+ releases are handled without requiring --k6-repo.
-	resolveK6Repo(ctx, opts)
+	if err := resolveK6Repo(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	return buildK6Binary(ctx, opts)
+}
 
+// buildK6Binary builds k6 for opts.os/opts.arch into opts.output, using the already resolved k6 module.
+func buildK6Binary(ctx context.Context, opts *buildOptions) (*k6foundry.BuildInfo, error) {
 	foundry, err := newFoundry(ctx, opts)
 	if err != nil {
 		return nil, err
@@ -221,8 +271,8 @@ func buildK6(ctx context.Context, opts *buildOptions) (*k6foundry.BuildInfo, err
 		platform,
 		opts.k6version,
 		opts.extensions.modules,
-		opts.replacements.modules,
-		opts.buildFlags,
+		append(slices.Clone(opts.replacements.modules), opts.pins...),
+		goBuildFlags(opts),
 		out,
 	)
 	if err != nil {
@@ -242,6 +292,9 @@ func buildK6(ctx context.Context, opts *buildOptions) (*k6foundry.BuildInfo, err
 type modules struct {
 	replace bool
 	modules []k6foundry.Module
+	// constraints contains the semver range constraints of the modules (by module path),
+	// they are resolved to exact versions by resolveConstraints before the build.
+	constraints map[string]string
 }
 
 func (m *modules) String() string {
@@ -252,6 +305,12 @@ func (m *modules) String() string {
 			buff.WriteRune(',')
 		}
 
+		if constraint, found := m.constraints[m.modules[idx].Path]; found {
+			buff.WriteString(m.modules[idx].Path + "@" + constraint)
+
+			continue
+		}
+
 		buff.WriteString(m.modules[idx].String())
 	}
 
@@ -259,11 +318,41 @@ func (m *modules) String() string {
 }
 
 func (m *modules) Set(val string) error {
+	// A local directory is replaced with its module path, read from the go.mod in the directory.
+	if !m.replace && modfile.IsDirectoryPath(val) {
+		mod, err := localModule(val)
+		if err != nil {
+			return err
+		}
+
+		m.modules = append(m.modules, mod)
+
+		return nil
+	}
+
+	// k6foundry only understands exact versions, so a semver range constraint is
+	// stripped before parsing and kept aside until it is resolved.
+	var constraint string
+
+	// Constraints may contain '=' (e.g. >=1.2), so they cannot be combined with a replacement.
+	if path, version, found := strings.Cut(val, "@"); found && !m.replace && sync.IsConstraint(version) {
+		constraint = version
+		val = path
+	}
+
 	mod, err := k6foundry.ParseModule(val)
 	if err != nil {
 		return err
 	}
 
+	if len(constraint) != 0 {
+		if m.constraints == nil {
+			m.constraints = make(map[string]string)
+		}
+
+		m.constraints[mod.Path] = constraint
+	}
+
 	if m.replace {
 		if len(mod.ReplacePath) == 0 {
 			return fmt.Errorf("%w: missing replace", k6foundry.ErrInvalidDependencyFormat)
@@ -279,11 +368,105 @@ func (m *modules) Set(val string) error {
 	return nil
 }
 
+// localModule returns the extension module in the local directory, replaced with the absolute path of the directory.
+func localModule(dir string) (k6foundry.Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return k6foundry.Module{}, err
+	}
+
+	path, err := getModulePath(dir)
+	if err != nil {
+		return k6foundry.Module{}, fmt.Errorf("%w: %s is not a Go module directory: %w",
+			k6foundry.ErrInvalidDependencyFormat, dir, err)
+	}
+
+	return k6foundry.Module{Path: path, ReplacePath: dir}, nil
+}
+
+// resolveVersions resolves the version queries of the build that k6foundry cannot handle:
+// semver range constraints, and extensions without version when the k6 version is pinned.
+func resolveVersions(ctx context.Context, opts *buildOptions) error {
+	if err := resolveConstraints(ctx, opts); err != nil {
+		return err
+	}
+
+	return resolveCompatibleExtensions(ctx, opts)
+}
+
+// resolveCompatibleExtensions picks, for the extensions requested without version, the newest version
+// that works with the pinned k6 version, instead of their latest version that may require a newer k6.
+func resolveCompatibleExtensions(ctx context.Context, opts *buildOptions) error {
+	if base, _, _ := module.SplitPathVersion(opts.k6repo); base != defaultK6Repo || !semver.IsValid(opts.k6version) {
+		return nil
+	}
+
+	for idx := range opts.extensions.modules {
+		mod := &opts.extensions.modules[idx]
+
+		if len(mod.ReplacePath) != 0 || (len(mod.Version) != 0 && mod.Version != defaultK6Version) {
+			continue
+		}
+
+		version, err := sync.ResolveCompatibleVersion(ctx, mod.Path, opts.k6version)
+		if err != nil {
+			return err
+		}
+
+		slog.Debug("Resolved extension version compatible with k6",
+			"module", mod.Path, "version", version, "k6", opts.k6version)
+
+		mod.Version = version
+	}
+
+	return nil
+}
+
+// resolveConstraints resolves the semver range constraints of the k6 version and the extensions
+// to the highest matching releases. The module paths are updated with the major version suffix
+// of the resolved versions.
+func resolveConstraints(ctx context.Context, opts *buildOptions) error {
+	if sync.IsConstraint(opts.k6version) {
+		path, version, err := sync.ResolveConstraint(ctx, opts.k6repo, opts.k6version)
+		if err != nil {
+			return err
+		}
+
+		slog.Debug("Resolved k6 version constraint", "constraint", opts.k6version, "repo", path, "version", version)
+
+		opts.k6repo, opts.k6version = path, version
+	}
+
+	for idx := range opts.extensions.modules {
+		mod := &opts.extensions.modules[idx]
+
+		constraint, found := opts.extensions.constraints[mod.Path]
+		if !found {
+			continue
+		}
+
+		path, version, err := sync.ResolveConstraint(ctx, mod.Path, constraint)
+		if err != nil {
+			return err
+		}
+
+		slog.Debug("Resolved extension version constraint", "module", path, "constraint", constraint, "version", version)
+
+		delete(opts.extensions.constraints, mod.Path)
+
+		mod.Path, mod.Version = path, version
+	}
+
+	return nil
+}
+
 // resolveK6Repo sets opts.k6repo (and opts.k6version when appropriate) to the
 // correct versioned module path, appending the /vN suffix when needed.
 // For the default repo with no explicit version, extension dependencies are
 // inspected first so their declared k6 version drives the build.
-func resolveK6Repo(ctx context.Context, opts *buildOptions) {
+// Resolution failures are logged and the repo is used as-is, except for conflicting
+// k6 requirements of the extensions, which are returned as an error.
+func resolveK6Repo(ctx context.Context, opts *buildOptions) error {
 	// Validate the module path early so callers get a clear error rather than
 	// a confusing failure deep in the Go toolchain. Strip any /vN suffix first
 	// since CheckPath expects a bare module path without the major-version suffix.
@@ -295,7 +478,8 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 	// User already included a /vN suffix — trust it as-is.
 	if _, pathMajor, ok := module.SplitPathVersion(opts.k6repo); ok && pathMajor != "" {
 		slog.Debug("Using k6 repo with explicit major version suffix", "repo", opts.k6repo)
-		return
+
+		return checkK6Conflicts(ctx, opts)
 	}
 
 	if opts.k6version == defaultK6Version {
@@ -305,9 +489,13 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 			slog.Debug("Resolving k6 module from extension dependencies (version: latest)")
 
 			path, version, err := sync.ResolveK6ModuleForExtensions(ctx, extensionModules(opts))
+			if errors.Is(err, sync.ErrK6Conflict) {
+				return err
+			}
+
 			if err != nil {
 				slog.Warn("Failed to resolve k6 module from extensions, using default", "error", err)
-				return
+				return nil
 			}
 
 			slog.Debug("Resolved k6 module", "repo", path, "version", version)
@@ -315,7 +503,7 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 			opts.k6repo = path
 			opts.k6version = version
 
-			return
+			return nil
 		}
 
 		slog.Debug("Resolving latest version for k6 repo", "repo", opts.k6repo)
@@ -323,7 +511,7 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 		path, version, err := sync.GetOverallLatestVersionFor(ctx, opts.k6repo)
 		if err != nil {
 			slog.Warn("Failed to resolve k6 repo latest version, using as-is", "repo", opts.k6repo, "error", err)
-			return
+			return nil
 		}
 
 		slog.Debug("Resolved k6 repo", "repo", path, "version", version)
@@ -331,7 +519,7 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 		opts.k6repo = path
 		opts.k6version = version
 
-		return
+		return nil
 	}
 
 	// Explicit version (semver, SHA, branch): detect the versioned module path
@@ -341,12 +529,28 @@ func resolveK6Repo(ctx context.Context, opts *buildOptions) {
 	path, err := sync.ResolveModuleForVersion(ctx, opts.k6repo, opts.k6version)
 	if err != nil {
 		slog.Warn("Failed to resolve k6 repo module path, using as-is", "repo", opts.k6repo, "error", err)
-		return
+		return nil
 	}
 
 	slog.Debug("Resolved k6 repo path", "repo", path)
 
 	opts.k6repo = path
+
+	return checkK6Conflicts(ctx, opts)
+}
+
+// checkK6Conflicts checks that every extension requires the major version of the pinned k6 module,
+// so the conflict is reported before the build instead of failing deep in the Go toolchain.
+// Forks are not checked, as the extensions require the original k6 module.
+func checkK6Conflicts(ctx context.Context, opts *buildOptions) error {
+	base, _, _ := module.SplitPathVersion(opts.k6repo)
+	if base != defaultK6Repo || len(opts.extensions.modules) == 0 {
+		return nil
+	}
+
+	reqs := sync.K6Requirements(ctx, extensionModules(opts))
+
+	return sync.CheckK6Requirements("--k6-version", opts.k6repo, opts.k6version, reqs)
 }
 
 func extensionModules(opts *buildOptions) []sync.ExtensionModule {
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/sync"
 )
 
 func TestResolveK6Repo_CustomV2SHA(t *testing.T) {
@@ -131,3 +137,183 @@ func TestResolveK6Repo_CustomLatest(t *testing.T) {
 		t.Errorf("expected k6version v2.1.0, got %s", opts.k6version)
 	}
 }
+
+func TestModules_SetConstraint(t *testing.T) {
+	t.Parallel()
+
+	mods := new(modules)
+
+	if err := mods.Set("github.com/grafana/xk6-sql@^1.2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mods.Set("github.com/grafana/xk6-faker@v0.4.0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mods.modules[0].Version != "" || mods.constraints["github.com/grafana/xk6-sql"] != "^1.2" {
+		t.Errorf("unexpected module: %+v, constraints: %v", mods.modules[0], mods.constraints)
+	}
+
+	if got := mods.String(); got != "github.com/grafana/xk6-sql@^1.2,github.com/grafana/xk6-faker@v0.4.0" {
+		t.Errorf("unexpected string: %s", got)
+	}
+}
+
+func TestModules_SetLocalDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/grafana/xk6-foo\n"), 0o600) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mods := new(modules)
+
+	if err := mods.Set(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := k6foundry.Module{Path: "github.com/grafana/xk6-foo", ReplacePath: dir}
+	if mods.modules[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, mods.modules[0])
+	}
+
+	if err := mods.Set(filepath.Join(dir, "missing")); !errors.Is(err, k6foundry.ErrInvalidDependencyFormat) {
+		t.Errorf("expected invalid dependency error, got %v", err)
+	}
+}
+
+func TestResolveConstraints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go.k6.io/k6/@v/list":
+			_, _ = fmt.Fprint(w, "v1.3.0\nv1.3.2\nv1.4.0\n")
+		case "/go.k6.io/k6/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v1.4.0"}`)
+		case "/go.k6.io/k6/@v/v1.4.0.mod":
+			_, _ = fmt.Fprint(w, "module go.k6.io/k6\n")
+		case "/github.com/grafana/xk6-sql/@v/list":
+			_, _ = fmt.Fprint(w, "v1.2.0\nv1.2.1\n")
+		case "/github.com/grafana/xk6-sql/v2/@v/list":
+			_, _ = fmt.Fprint(w, "v2.0.0\n")
+		case "/github.com/grafana/xk6-sql/v2/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v2.0.0"}`)
+		case "/github.com/grafana/xk6-sql/v2/@v/v2.0.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-sql/v2\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GOPROXY", srv.URL)
+
+	opts := newBuildOptions()
+	opts.k6repo = defaultK6Repo
+	opts.k6version = "~1.3"
+
+	if err := opts.extensions.Set("github.com/grafana/xk6-sql@^2.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resolveConstraints(t.Context(), opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6repo != defaultK6Repo || opts.k6version != "v1.3.2" {
+		t.Errorf("unexpected k6: %s@%s", opts.k6repo, opts.k6version)
+	}
+
+	if got := opts.extensions.String(); got != "github.com/grafana/xk6-sql/v2@v2.0.0" {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+}
+
+func TestResolveCompatibleExtensions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/grafana/xk6-bar/@v/list":
+			_, _ = fmt.Fprint(w, "v0.1.0\nv0.2.0\n")
+		case "/github.com/grafana/xk6-bar/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v0.2.0"}`)
+		case "/github.com/grafana/xk6-bar/@v/v0.2.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.4.0\n")
+		case "/github.com/grafana/xk6-bar/@v/v0.1.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.1.0\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GOPROXY", srv.URL)
+
+	opts := newBuildOptions()
+	opts.k6repo = defaultK6Repo
+	opts.k6version = "v1.2.0"
+
+	for _, value := range []string{"github.com/grafana/xk6-bar", "github.com/grafana/xk6-faker@v0.4.0"} {
+		if err := opts.extensions.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := resolveCompatibleExtensions(t.Context(), opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := opts.extensions.String(); got != "github.com/grafana/xk6-bar@v0.1.0,github.com/grafana/xk6-faker@v0.4.0" {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+}
+
+func TestResolveK6Repo_PinnedConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/github.com/grafana/xk6-next/@v/v1.0.0.mod" {
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-next\n\nrequire go.k6.io/k6/v2 v2.0.0\n")
+
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	t.Setenv("GOPROXY", srv.URL)
+
+	opts := newBuildOptions()
+	opts.k6repo = defaultK6Repo
+	opts.k6version = "v1.2.0"
+
+	if err := opts.extensions.Set("github.com/grafana/xk6-next@v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := resolveK6Repo(t.Context(), opts)
+	if !errors.Is(err, sync.ErrK6Conflict) {
+		t.Fatalf("expected ErrK6Conflict, got %v", err)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv("XK6_TEST_SET", "prev")
+
+	err := withEnv(map[string]string{"XK6_TEST_SET": "new", "XK6_TEST_UNSET": "new"}, func() error {
+		if os.Getenv("XK6_TEST_SET") != "new" || os.Getenv("XK6_TEST_UNSET") != "new" { //nolint:forbidigo
+			t.Error("expected the environment to be set")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("XK6_TEST_SET"); got != "prev" { //nolint:forbidigo
+		t.Errorf("expected the previous value to be restored, got %q", got)
+	}
+
+	if _, found := os.LookupEnv("XK6_TEST_UNSET"); found { //nolint:forbidigo
+		t.Error("expected the variable to be unset")
+	}
+}
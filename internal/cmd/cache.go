@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/cache"
+	"go.k6.io/xk6/internal/sync"
+)
+
+var (
+	//go:embed help/cache.md
+	cacheHelp string
+
+	//go:embed help/cache-ls.md
+	cacheLsHelp string
+
+	//go:embed help/cache-prune.md
+	cachePruneHelp string
+
+	//go:embed help/cache-clean.md
+	cacheCleanHelp string
+)
+
+const (
+	defaultCacheMaxAge = 30 * 24 * time.Hour
+	shortKeyLength     = 12
+)
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "cache",
+		Short:             shortHelp(cacheHelp),
+		Long:              cacheHelp,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(cacheLsCmd(), cachePruneCmd(), cacheCleanCmd())
+
+	return cmd
+}
+
+func cacheLsCmd() *cobra.Command {
+	var (
+		json    bool
+		compact bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ls [flags]",
+		Short: shortHelp(cacheLsHelp),
+		Long:  cacheLsHelp,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			store, err := cache.Open("")
+			if err != nil {
+				return err
+			}
+
+			entries, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			if json || compact {
+				if entries == nil {
+					entries = []*cache.Entry{}
+				}
+
+				return jsonOutput(entries, cmd.OutOrStdout(), compact)
+			}
+
+			textCacheOutput(entries, cmd.OutOrStdout())
+
+			return nil
+		},
+		DisableAutoGenTag: true,
+	}
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.BoolVar(&json, "json", false, "Generate JSON output")
+	flags.BoolVarP(&compact, "compact", "c", false, "Compact instead of pretty-printed JSON output")
+
+	return cmd
+}
+
+func cachePruneCmd() *cobra.Command {
+	var maxAge time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "prune [flags]",
+		Short: shortHelp(cachePruneHelp),
+		Long:  cachePruneHelp,
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			store, err := cache.Open("")
+			if err != nil {
+				return err
+			}
+
+			removed, err := store.Prune(maxAge)
+
+			for _, entry := range removed {
+				slog.Info("Removed", "key", shortKey(entry.Key), "k6", entry.K6Version, "last_used", entry.LastUsed)
+			}
+
+			return err
+		},
+		DisableAutoGenTag: true,
+	}
+
+	cmd.Flags().DurationVar(&maxAge, "max-age", defaultCacheMaxAge, "Remove binaries not used for longer than this")
+
+	return cmd
+}
+
+func cacheCleanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: shortHelp(cacheCleanHelp),
+		Long:  cacheCleanHelp,
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			store, err := cache.Open("")
+			if err != nil {
+				return err
+			}
+
+			slog.Info("Cleaning cache", "dir", store.Dir())
+
+			if err := store.Clean(); err != nil {
+				return err
+			}
+
+			return sync.CleanProxyCache()
+		},
+		DisableAutoGenTag: true,
+	}
+
+	return cmd
+}
+
+func shortKey(key string) string {
+	if len(key) > shortKeyLength {
+		return key[:shortKeyLength]
+	}
+
+	return key
+}
+
+func textCacheOutput(entries []*cache.Entry, output io.Writer) {
+	heading := color.New(color.FgHiWhite, color.Bold).FprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
+	faint := color.New(color.FgBlack).FprintfFunc()
+
+	if len(entries) == 0 {
+		plain(output, "The cache is empty.\n")
+
+		return
+	}
+
+	var total int64
+
+	for _, entry := range entries {
+		total += entry.Size
+
+		heading(output, "%s", shortKey(entry.Key))
+		plain(output, " k6 %s %s %s\n", entry.K6Version, entry.Platform, formatSize(entry.Size))
+		faint(output, "  last used %s\n", entry.LastUsed.Format(time.RFC3339))
+
+		if len(entry.Extensions) != 0 {
+			plain(output, "  %s\n", strings.Join(entry.Extensions, "\n  "))
+		}
+	}
+
+	plain(output, "\n%d binaries, %s\n", len(entries), formatSize(total))
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
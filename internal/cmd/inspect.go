@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	_ "embed"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/inspect"
+	"go.k6.io/xk6/internal/sync"
+)
+
+//go:embed help/inspect.md
+var inspectHelp string
+
+// inspectOutput is the JSON output of the inspect command.
+type inspectOutput struct {
+	*inspect.Composition
+
+	Command string `json:"command"`
+}
+
+func inspectCmd() *cobra.Command {
+	var (
+		json    bool
+		compact bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inspect [flags] binary",
+		Short: shortHelp(inspectHelp),
+		Long:  inspectHelp,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			comp, err := inspect.Inspect(cmd.Context(), args[0], sync.ModuleRequirements)
+			if err != nil {
+				return err
+			}
+
+			if json || compact {
+				return jsonOutput(&inspectOutput{Composition: comp, Command: reproduceCommand(comp)}, cmd.OutOrStdout(), compact)
+			}
+
+			textInspectOutput(comp, cmd.OutOrStdout())
+
+			return nil
+		},
+		DisableAutoGenTag: true,
+	}
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.BoolVar(&json, "json", false, "Generate JSON output")
+	flags.BoolVarP(&compact, "compact", "c", false, "Compact instead of pretty-printed JSON output")
+
+	return cmd
+}
+
+func textInspectOutput(comp *inspect.Composition, output io.Writer) {
+	heading := color.New(color.FgHiWhite, color.Bold).FprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
+	faint := color.New(color.FgBlack).FprintfFunc()
+
+	heading(output, "k6 %s", comp.K6.Version)
+	plain(output, " (%s)\n", comp.K6.String())
+
+	cgo := "disabled"
+	if comp.Cgo {
+		cgo = "enabled"
+	}
+
+	platform := comp.OS + "/" + comp.Arch
+	if len(comp.ARM) != 0 {
+		platform += "/v" + comp.ARM
+	}
+
+	faint(output, "  %s %s, cgo %s", comp.GoVersion, platform, cgo)
+
+	if comp.RaceDetector {
+		faint(output, ", race detector enabled")
+	}
+
+	faint(output, "\n")
+
+	if len(comp.BuildFlags) != 0 {
+		faint(output, "  build flags: %s\n", strings.Join(comp.BuildFlags, " "))
+	}
+
+	heading(output, "\nExtensions\n")
+
+	if len(comp.Extensions) == 0 {
+		plain(output, "  none\n")
+	}
+
+	for _, ext := range comp.Extensions {
+		plain(output, "  %s\n", ext.String())
+	}
+
+	if len(comp.Replacements) != 0 {
+		heading(output, "\nReplacements\n")
+
+		for _, rep := range comp.Replacements {
+			plain(output, "  %s => %s\n", rep.Path, rep.Replace)
+		}
+	}
+
+	heading(output, "\nReproduce\n")
+	plain(output, "  %s\n", reproduceCommand(comp))
+}
+
+// reproduceCommand returns the xk6 command line that reproduces the binary.
+func reproduceCommand(comp *inspect.Composition) string {
+	args := comp.Command()
+
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, appname)
+
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes the argument for POSIX shells, if needed.
+func shellQuote(arg string) string {
+	if len(arg) != 0 && !strings.ContainsAny(arg, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import "testing"
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"github.com/grafana/xk6-faker@v0.4.0": "github.com/grafana/xk6-faker@v0.4.0",
+		"-ldflags=-s -w":                      "'-ldflags=-s -w'",
+		"it's":                                `'it'\''s'`,
+		"":                                    "''",
+	}
+
+	for arg, expected := range tests {
+		if got := shellQuote(arg); got != expected {
+			t.Errorf("shellQuote(%q) = %s, expected %s", arg, got, expected)
+		}
+	}
+}
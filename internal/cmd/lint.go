@@ -128,6 +128,7 @@ type options struct {
 	enableOnly checkIDs
 	k6version  string
 	k6repo     string
+	prerelease prereleasePolicy
 }
 
 func lintCmd() *cobra.Command {
@@ -150,7 +151,7 @@ func lintCmd() *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := lintRunE(cmd.Context(), args, opts)
+			err := lintRunE(opts.prerelease.context(cmd.Context()), args, opts)
 			if errors.Is(err, errLintingFailed) {
 				slog.Error(errLintingFailed.Error())
 				os.Exit(exitCodeLintingFailed) //nolint:forbidigo
@@ -178,6 +179,8 @@ func lintCmd() *cobra.Command {
 	flags.StringVarP(&opts.k6version, "k6-version", "k", defaultK6Version, "The k6 version to use for build")
 	flags.StringVar(&opts.k6repo, "k6-repo", defaultK6Repo, "The k6 repository to use for the build")
 
+	cobra.CheckErr(prereleaseFlag(flags, &opts.prerelease))
+
 	env := efa.New(flags, appname+"_"+cmd.Name(), nil)
 
 	cobra.CheckErr(env.Bind("preset", "enable", "disable", "enable-only"))
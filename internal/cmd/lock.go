@@ -0,0 +1,447 @@
+package cmd
+
+import (
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/inspect"
+	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/modfile"
+)
+
+const lockFilePerm = 0o644
+
+var (
+	errLockMismatch   = errors.New("build does not match the lock file")
+	errLockSettings   = errors.New("build settings do not match the lock file")
+	errNotInLockFile  = errors.New("extension is not in the lock file")
+	errInvalidLockVer = errors.New("unsupported lock file version")
+)
+
+const lockFileVersion = 1
+
+// lockFile records the exact composition of a k6 build, so it can be reproduced later.
+type lockFile struct {
+	Version int `json:"version"`
+	// Go is the version of the Go toolchain used for the build.
+	Go string `json:"go"`
+	// Platforms contains the target platforms (os/arch) of the build.
+	Platforms []string `json:"platforms"`
+	// ARM is the target ARM version, if any.
+	ARM string `json:"arm,omitempty"`
+	// Cgo is true if cgo was enabled.
+	Cgo bool `json:"cgo"`
+	// BuildFlags contains the Go build flags, including -race if the race detector was enabled.
+	BuildFlags   []string     `json:"build_flags"`
+	K6           lockModule   `json:"k6"`
+	Extensions   []lockModule `json:"extensions,omitempty"`
+	Replacements []lockModule `json:"replacements,omitempty"`
+	// ModuleSums contains the checksums of the modules compiled into the binaries, in go.sum format.
+	// It is not the go.sum of the build module: the go.mod checksums and the modules of the module graph
+	// that are not compiled into any of the binaries are missing, only the embedded build information is used.
+	ModuleSums string `json:"module_sums"`
+}
+
+type lockModule struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	// Hash is the hash of the local directory the module is replaced with.
+	Hash string `json:"hash,omitempty"`
+}
+
+func readLockFile(filename string) (*lockFile, error) {
+	data, err := os.ReadFile(filepath.Clean(filename)) //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	lock := new(lockFile)
+
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if lock.Version != lockFileVersion {
+		return nil, fmt.Errorf("%w: %d", errInvalidLockVer, lock.Version)
+	}
+
+	return lock, nil
+}
+
+func (l *lockFile) write(filename string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), lockFilePerm) //nolint:forbidigo
+}
+
+// newLockFile creates the lock file of a finished build from the build options and the built binaries.
+func newLockFile(
+	ctx context.Context, opts *buildOptions, info *k6foundry.BuildInfo, outputs []string,
+) (*lockFile, error) {
+	platforms, err := lockPlatforms(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	goVersion, sums, err := binarySums(outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &lockFile{
+		Version:    lockFileVersion,
+		Go:         goVersion,
+		Platforms:  platforms,
+		ARM:        opts.arm,
+		Cgo:        cgoEnabled(opts),
+		BuildFlags: goBuildFlags(opts),
+		K6:         lockModule{Path: opts.k6repo, Version: info.ModVersions[info.K6ModPath]},
+		ModuleSums: sums,
+	}
+
+	// The version reported for a fork is the version of the replaced module,
+	// the canonical version of the fork itself is recorded instead.
+	if version, err := sync.ResolveVersion(ctx, opts.k6repo, opts.k6version); err == nil {
+		lock.K6.Version = version
+	} else {
+		slog.Debug("Failed to resolve canonical k6 version", "repo", opts.k6repo, "error", err)
+	}
+
+	for _, mod := range opts.extensions.modules {
+		lmod, err := lockModuleFor(mod)
+		if err != nil {
+			return nil, err
+		}
+
+		lmod.Version = info.ModVersions[mod.Path]
+
+		lock.Extensions = append(lock.Extensions, lmod)
+	}
+
+	for _, mod := range opts.replacements.modules {
+		lmod, err := lockModuleFor(mod)
+		if err != nil {
+			return nil, err
+		}
+
+		lock.Replacements = append(lock.Replacements, lmod)
+	}
+
+	return lock, nil
+}
+
+// lockModuleFor returns the lock entry of a module, with the hash of the local directory it is replaced with.
+func lockModuleFor(mod k6foundry.Module) (lockModule, error) {
+	lmod := lockModule{Path: mod.Path}
+
+	if len(mod.ReplacePath) == 0 {
+		return lmod, nil
+	}
+
+	lmod.Replace = mod.ReplacePath
+	if len(mod.ReplaceVersion) != 0 {
+		lmod.Replace += "@" + mod.ReplaceVersion
+	}
+
+	if modfile.IsDirectoryPath(mod.ReplacePath) {
+		hash, err := hashDir(mod.ReplacePath)
+		if err != nil {
+			return lmod, err
+		}
+
+		lmod.Hash = hash
+	}
+
+	return lmod, nil
+}
+
+// lockPlatforms returns the target platforms of the build.
+func lockPlatforms(opts *buildOptions) ([]string, error) {
+	if len(opts.platforms) == 0 {
+		return []string{opts.os + "/" + opts.arch}, nil
+	}
+
+	platforms, err := parsePlatforms(opts.platforms)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		names = append(names, platform.String())
+	}
+
+	return names, nil
+}
+
+// binarySums returns the Go version and the checksums of the modules compiled into the binaries,
+// read from the build information embedded by the Go toolchain. Modules replaced with local
+// directories have no checksum, their directories are hashed in the lock file instead.
+func binarySums(binaries []string) (string, string, error) {
+	var goVersion string
+
+	lines := make([]string, 0)
+
+	for _, binary := range binaries {
+		info, err := buildinfo.ReadFile(binary)
+		if err != nil {
+			return "", "", err
+		}
+
+		goVersion = info.GoVersion
+
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+
+			if len(dep.Sum) != 0 {
+				lines = append(lines, dep.Path+" "+dep.Version+" "+dep.Sum)
+			}
+		}
+	}
+
+	slices.Sort(lines)
+
+	var buff strings.Builder
+
+	for _, line := range slices.Compact(lines) {
+		buff.WriteString(line + "\n")
+	}
+
+	return goVersion, buff.String(), nil
+}
+
+// applyLockFile pins the build options to the composition recorded in the lock file.
+// Extensions given by flags must be present in the lock file, and the build settings (Go toolchain,
+// platforms, cgo, build flags) and the content of the local directories must match the lock file.
+func applyLockFile(ctx context.Context, opts *buildOptions, lock *lockFile) error {
+	for _, mod := range opts.extensions.modules {
+		if !slices.ContainsFunc(lock.Extensions, func(lmod lockModule) bool { return lmod.Path == mod.Path }) {
+			return fmt.Errorf("%w: %s", errNotInLockFile, mod.Path)
+		}
+	}
+
+	if err := checkLockSettings(ctx, opts, lock); err != nil {
+		return err
+	}
+
+	opts.k6repo = lock.K6.Path
+	opts.k6version = lock.K6.Version
+
+	extensions := new(modules)
+
+	for _, lmod := range lock.Extensions {
+		value := lmod.Path + "@" + lmod.Version
+		if len(lmod.Replace) != 0 {
+			value = lmod.Path + "=" + lmod.Replace
+		}
+
+		if err := extensions.Set(value); err != nil {
+			return err
+		}
+	}
+
+	opts.extensions = extensions
+
+	replacements := &modules{replace: true}
+
+	for _, lmod := range lock.Replacements {
+		if err := replacements.Set(lmod.Path + "=" + lmod.Replace); err != nil {
+			return err
+		}
+	}
+
+	opts.replacements = replacements
+
+	for _, lmod := range slices.Concat(lock.Extensions, lock.Replacements) {
+		if len(lmod.Hash) == 0 {
+			continue
+		}
+
+		hash, err := hashDir(lmod.Replace)
+		if err != nil {
+			return err
+		}
+
+		if hash != lmod.Hash {
+			return fmt.Errorf("%w: %s: the content of %s has changed", errLockSettings, lmod.Path, lmod.Replace)
+		}
+	}
+
+	opts.pins = lockPins(lock)
+
+	return nil
+}
+
+// checkLockSettings returns an error if the build settings differ from the ones recorded in the lock file.
+func checkLockSettings(ctx context.Context, opts *buildOptions, lock *lockFile) error {
+	goVersion, err := goToolchainVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	platforms, err := lockPlatforms(opts)
+	if err != nil {
+		return err
+	}
+
+	diffs := make([]string, 0)
+
+	if goVersion != lock.Go {
+		diffs = append(diffs, fmt.Sprintf("go: locked %s, current %s (set GOTOOLCHAIN=%s)", lock.Go, goVersion, lock.Go))
+	}
+
+	if !slices.Equal(platforms, lock.Platforms) || opts.arm != lock.ARM {
+		diffs = append(diffs, fmt.Sprintf("platforms: locked %s, requested %s",
+			platformsString(lock.Platforms, lock.ARM), platformsString(platforms, opts.arm)))
+	}
+
+	if cgo := cgoEnabled(opts); cgo != lock.Cgo {
+		diffs = append(diffs, fmt.Sprintf("cgo: locked %t, requested %t", lock.Cgo, cgo))
+	}
+
+	if flags := goBuildFlags(opts); !slices.Equal(flags, lock.BuildFlags) {
+		diffs = append(diffs, fmt.Sprintf("build flags: locked %q, requested %q", lock.BuildFlags, flags))
+	}
+
+	if len(diffs) != 0 {
+		return fmt.Errorf("%w:\n  %s", errLockSettings, strings.Join(diffs, "\n  "))
+	}
+
+	return nil
+}
+
+func platformsString(platforms []string, arm string) string {
+	str := strings.Join(platforms, ",")
+	if len(arm) != 0 {
+		str += " (arm " + arm + ")"
+	}
+
+	return str
+}
+
+// lockPins returns the modules of the locked module checksums that are not pinned by the lock file otherwise.
+// They are replaced with themselves at the locked version, so the build resolves the locked module
+// graph up front instead of whatever the requirements of k6 and the extensions would select today.
+func lockPins(lock *lockFile) []k6foundry.Module {
+	pinned := map[string]struct{}{lock.K6.Path: {}}
+
+	for _, lmod := range slices.Concat(lock.Extensions, lock.Replacements) {
+		pinned[lmod.Path] = struct{}{}
+
+		if path, _, _ := strings.Cut(lmod.Replace, "@"); len(path) != 0 {
+			pinned[path] = struct{}{}
+		}
+	}
+
+	pins := make([]k6foundry.Module, 0)
+
+	for line := range strings.Lines(lock.ModuleSums) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 { //nolint:mnd
+			continue
+		}
+
+		path, version := fields[0], fields[1]
+
+		if _, found := pinned[path]; found || inspect.IsK6Module(path) {
+			continue
+		}
+
+		pinned[path] = struct{}{}
+
+		pins = append(pins, k6foundry.Module{Path: path, ReplacePath: path, ReplaceVersion: version})
+	}
+
+	return pins
+}
+
+// diff returns the human readable differences between the expected (locked) and the actual build.
+func (l *lockFile) diff(actual *lockFile) []string {
+	diffs := make([]string, 0)
+
+	if l.Go != actual.Go {
+		diffs = append(diffs, fmt.Sprintf("go: locked %s, built with %s", l.Go, actual.Go))
+	}
+
+	if l.K6 != actual.K6 {
+		diffs = append(diffs, fmt.Sprintf("k6: locked %s@%s, resolved %s@%s",
+			l.K6.Path, l.K6.Version, actual.K6.Path, actual.K6.Version))
+	}
+
+	for _, expected := range l.Extensions {
+		idx := slices.IndexFunc(actual.Extensions, func(m lockModule) bool { return m.Path == expected.Path })
+		if idx < 0 {
+			diffs = append(diffs, fmt.Sprintf("%s: missing from the build", expected.Path))
+
+			continue
+		}
+
+		if got := actual.Extensions[idx]; got.Version != expected.Version {
+			diffs = append(diffs, fmt.Sprintf("%s: locked %s, resolved %s", expected.Path, expected.Version, got.Version))
+		} else if got.Hash != expected.Hash {
+			diffs = append(diffs, fmt.Sprintf("%s: the content of %s has changed", expected.Path, expected.Replace))
+		}
+	}
+
+	if !slices.Equal(l.Replacements, actual.Replacements) {
+		diffs = append(diffs, "replacements: the replaced modules differ from the locked ones")
+	}
+
+	if l.ModuleSums != actual.ModuleSums {
+		diffs = append(diffs, "module sums: the modules compiled into the build differ from the locked ones")
+	}
+
+	return diffs
+}
+
+// lockBuild verifies the build against the locked composition and writes the lock file, as requested.
+// On mismatch the built binaries are removed, so a build that does not match the lock file is never shipped.
+func lockBuild(
+	ctx context.Context, opts *buildOptions, locked *lockFile, info *k6foundry.BuildInfo, outputs []string,
+) error {
+	if locked == nil && len(opts.lock) == 0 {
+		return nil
+	}
+
+	actual, err := newLockFile(ctx, opts, info, outputs)
+	if err != nil {
+		return err
+	}
+
+	if locked != nil {
+		if diffs := locked.diff(actual); len(diffs) != 0 {
+			for _, output := range outputs {
+				_ = os.Remove(output) //nolint:forbidigo
+			}
+
+			return fmt.Errorf("%w:\n  %s", errLockMismatch, strings.Join(diffs, "\n  "))
+		}
+
+		slog.Info("Build matches the lock file", "lock", opts.locked)
+	}
+
+	if len(opts.lock) != 0 {
+		if err := actual.write(opts.lock); err != nil {
+			return err
+		}
+
+		slog.Info("Lock file written", "lock", opts.lock)
+	}
+
+	return nil
+}
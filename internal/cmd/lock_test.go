@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/grafana/k6foundry"
+)
+
+func testLockFile() *lockFile {
+	return &lockFile{
+		Version:    lockFileVersion,
+		Go:         runtime.Version(),
+		Platforms:  []string{"linux/amd64"},
+		BuildFlags: []string{"-trimpath"},
+		K6:         lockModule{Path: "go.k6.io/k6", Version: "v1.2.0"},
+		Extensions: []lockModule{
+			{Path: "github.com/grafana/xk6-faker", Version: "v0.4.0"},
+			{Path: "github.com/grafana/xk6-remote", Replace: "github.com/myorg/xk6-remote@v0.1.0"},
+		},
+		Replacements: []lockModule{{Path: "github.com/foo/bar", Replace: "github.com/myorg/bar@v1.0.1"}},
+		ModuleSums: "github.com/grafana/xk6-faker v0.4.0 h1:faker=\n" +
+			"github.com/myorg/bar v1.0.1 h1:bar=\n" +
+			"github.com/spf13/afero v1.1.0 h1:afero=\n" +
+			"go.k6.io/k6 v1.2.0 h1:k6=\n",
+	}
+}
+
+// testLockOptions returns build options matching the settings of testLockFile.
+func testLockOptions(t *testing.T) (*buildOptions, *lockFile) {
+	t.Helper()
+
+	goVersion, err := goToolchainVersion(t.Context())
+	if err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+
+	lock := testLockFile()
+	lock.Go = goVersion
+
+	opts := newBuildOptions()
+	opts.k6version = defaultK6Version
+	opts.os = "linux"
+	opts.arch = "amd64"
+	opts.buildFlags = []string{"-trimpath"}
+
+	return opts, lock
+}
+
+func TestLockFile_ReadWrite(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "xk6.lock")
+
+	lock := testLockFile()
+
+	if err := lock.write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readLockFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, lock) {
+		t.Errorf("expected %v, got %v", lock, got)
+	}
+}
+
+func TestApplyLockFile(t *testing.T) {
+	t.Parallel()
+
+	opts, lock := testLockOptions(t)
+
+	if err := opts.extensions.Set("github.com/grafana/xk6-faker"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyLockFile(t.Context(), opts, lock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6version != "v1.2.0" {
+		t.Errorf("expected k6version v1.2.0, got %s", opts.k6version)
+	}
+
+	expected := "github.com/grafana/xk6-faker@v0.4.0,github.com/grafana/xk6-remote => github.com/myorg/xk6-remote@v0.1.0"
+	if got := opts.extensions.String(); got != expected {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+
+	if got := opts.replacements.String(); got != "github.com/foo/bar => github.com/myorg/bar@v1.0.1" {
+		t.Errorf("unexpected replacements: %s", got)
+	}
+
+	// only the modules not pinned by the lock file otherwise
+	pins := []k6foundry.Module{
+		{Path: "github.com/spf13/afero", ReplacePath: "github.com/spf13/afero", ReplaceVersion: "v1.1.0"},
+	}
+
+	if !reflect.DeepEqual(opts.pins, pins) {
+		t.Errorf("expected pins %v, got %v", pins, opts.pins)
+	}
+}
+
+func TestApplyLockFile_UnknownExtension(t *testing.T) {
+	t.Parallel()
+
+	opts, lock := testLockOptions(t)
+
+	if err := opts.extensions.Set("github.com/grafana/xk6-sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyLockFile(t.Context(), opts, lock); !errors.Is(err, errNotInLockFile) {
+		t.Errorf("expected errNotInLockFile, got %v", err)
+	}
+}
+
+func TestApplyLockFile_SettingsMismatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]func(opts *buildOptions, lock *lockFile){
+		"toolchain":   func(_ *buildOptions, lock *lockFile) { lock.Go = "go1.0.0" },
+		"platform":    func(opts *buildOptions, _ *lockFile) { opts.arch = "arm64" },
+		"cgo":         func(opts *buildOptions, _ *lockFile) { opts.cgo = 1 },
+		"race":        func(opts *buildOptions, _ *lockFile) { opts.raceDetector = 1 },
+		"build flags": func(opts *buildOptions, _ *lockFile) { opts.buildFlags = nil },
+	}
+
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, lock := testLockOptions(t)
+
+			change(opts, lock)
+
+			if err := applyLockFile(t.Context(), opts, lock); !errors.Is(err, errLockSettings) {
+				t.Errorf("expected errLockSettings, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyLockFile_LocalDirectoryChanged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/grafana/xk6-local\n"), 0o600) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := hashDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, lock := testLockOptions(t)
+
+	lock.Extensions = append(lock.Extensions, lockModule{Path: "github.com/grafana/xk6-local", Replace: dir, Hash: hash})
+
+	if err := applyLockFile(t.Context(), opts, lock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "local.go"), []byte("package local\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	opts, _ = testLockOptions(t)
+
+	if err := applyLockFile(t.Context(), opts, lock); !errors.Is(err, errLockSettings) {
+		t.Errorf("expected errLockSettings, got %v", err)
+	}
+}
+
+func TestLockFile_Diff(t *testing.T) {
+	t.Parallel()
+
+	locked := testLockFile()
+
+	if diffs := locked.diff(testLockFile()); len(diffs) != 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+
+	actual := testLockFile()
+	actual.Go = "go1.0.0"
+	actual.Extensions[0].Version = "v0.4.1"
+	actual.ModuleSums = ""
+
+	if diffs := locked.diff(actual); len(diffs) != 3 {
+		t.Errorf("expected 2 differences, got %v", diffs)
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the tests use short-lived local proxies, Go proxy responses must not be served from the cache
+	_ = os.Setenv("XK6_PROXY_CACHE_TTL", "off") //nolint:forbidigo
+	// the modules served by the test proxies are not in the public checksum database
+	_ = os.Setenv("GOSUMDB", "off") //nolint:forbidigo
+
+	os.Exit(m.Run()) //nolint:forbidigo
+}
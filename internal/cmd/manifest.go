@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/mod/modfile"
+	"gopkg.in/yaml.v3"
+)
+
+// manifest is the declarative form of the build flags, usually stored in an xk6.yaml file.
+type manifest struct {
+	K6           manifestK6 `yaml:"k6"`
+	With         []string   `yaml:"with"`
+	Replace      []string   `yaml:"replace"`
+	OS           string     `yaml:"os"`
+	Arch         string     `yaml:"arch"`
+	ARM          string     `yaml:"arm"`
+	Cgo          *bool      `yaml:"cgo"`
+	RaceDetector *bool      `yaml:"race-detector"`
+	BuildFlags   []string   `yaml:"build-flags"`
+	Platforms    []string   `yaml:"platforms"`
+	Output       string     `yaml:"output"`
+	Prerelease   string     `yaml:"prerelease"`
+}
+
+type manifestK6 struct {
+	Version string `yaml:"version"`
+	Repo    string `yaml:"repo"`
+}
+
+var errInvalidManifest = errors.New("invalid build manifest")
+
+func loadManifest(filename string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Clean(filename)) //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	mf := new(manifest)
+
+	if err := decoder.Decode(mf); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errInvalidManifest, filename, err)
+	}
+
+	return mf, nil
+}
+
+// applyManifest fills the build options from the manifest file referenced by the config flag.
+// Settings already given by a flag or an environment variable are left untouched.
+// Extensions and replacements are merged by module path, the ones given by flags win.
+func applyManifest(flags *pflag.FlagSet, opts *buildOptions) error {
+	if len(opts.config) == 0 {
+		return nil
+	}
+
+	mf, err := loadManifest(opts.config)
+	if err != nil {
+		return err
+	}
+
+	scalars := []struct {
+		name  string
+		value string
+	}{
+		{"k6-version", mf.K6.Version},
+		{"k6-repo", mf.K6.Repo},
+		{"os", mf.OS},
+		{"arch", mf.Arch},
+		{"arm", mf.ARM},
+		{"cgo", boolToFlag(mf.Cgo)},
+		{"race-detector", boolToFlag(mf.RaceDetector)},
+		{"output", mf.Output},
+		{"platform", strings.Join(mf.Platforms, ",")},
+		{"prerelease", mf.Prerelease},
+	}
+
+	for _, scalar := range scalars {
+		if err := setFlagDefault(flags, scalar.name, scalar.value); err != nil {
+			return err
+		}
+	}
+
+	if len(mf.BuildFlags) != 0 && !flags.Changed("build-flags") {
+		opts.buildFlags = slices.Clone(mf.BuildFlags)
+	}
+
+	// local directories in the manifest are relative to the manifest file
+	dir, err := filepath.Abs(filepath.Dir(opts.config))
+	if err != nil {
+		return err
+	}
+
+	if err := mergeModules(opts.extensions, mf.With, dir); err != nil {
+		return fmt.Errorf("%w: with: %w", errInvalidManifest, err)
+	}
+
+	if err := mergeModules(opts.replacements, mf.Replace, dir); err != nil {
+		return fmt.Errorf("%w: replace: %w", errInvalidManifest, err)
+	}
+
+	return nil
+}
+
+// setFlagDefault sets the named flag unless it has already been set or it is not defined for the command.
+func setFlagDefault(flags *pflag.FlagSet, name, value string) error {
+	if len(value) == 0 {
+		return nil
+	}
+
+	flag := flags.Lookup(name)
+	if flag == nil || flag.Changed {
+		return nil
+	}
+
+	if err := flags.Set(name, value); err != nil {
+		return fmt.Errorf("%w: %s: %w", errInvalidManifest, name, err)
+	}
+
+	return nil
+}
+
+// mergeModules adds the modules from the manifest whose path is not already present.
+// Relative local directories are resolved against dir.
+func mergeModules(mods *modules, values []string, dir string) error {
+	given := make([]string, 0, len(mods.modules))
+	for _, mod := range mods.modules {
+		given = append(given, mod.Path)
+	}
+
+	for _, value := range values {
+		parsed := &modules{replace: mods.replace}
+
+		if err := parsed.Set(manifestModule(value, dir)); err != nil {
+			return err
+		}
+
+		mod := parsed.modules[0]
+
+		if slices.Contains(given, mod.Path) {
+			continue
+		}
+
+		mods.modules = append(mods.modules, mod)
+
+		if constraint, found := parsed.constraints[mod.Path]; found {
+			if mods.constraints == nil {
+				mods.constraints = make(map[string]string)
+			}
+
+			mods.constraints[mod.Path] = constraint
+		}
+	}
+
+	return nil
+}
+
+// manifestModule resolves the relative local directory of a module value (as extension or as replacement) against dir.
+func manifestModule(value, dir string) string {
+	isRelative := func(path string) bool { return modfile.IsDirectoryPath(path) && !filepath.IsAbs(path) }
+
+	path, replace, found := strings.Cut(value, "=")
+
+	switch {
+	case !found && isRelative(path):
+		return filepath.Join(dir, path)
+	case found && isRelative(replace):
+		return path + "=" + filepath.Join(dir, replace)
+	default:
+		return value
+	}
+}
+
+func boolToFlag(value *bool) string {
+	if value == nil {
+		return ""
+	}
+
+	if *value {
+		return "1"
+	}
+
+	return "0"
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testManifest = `
+k6:
+  version: v1.2.0
+  repo: github.com/myfork/k6
+with:
+  - github.com/grafana/xk6-sql@v1.0.0
+  - github.com/grafana/xk6-faker@v0.4.0
+build-flags:
+  - -trimpath
+cgo: true
+`
+
+func writeTestManifest(t *testing.T, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "xk6.yaml")
+
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func TestApplyManifest_Defaults(t *testing.T) {
+	t.Parallel()
+
+	opts := newBuildOptions()
+	flags := pflag.NewFlagSet("build", pflag.ContinueOnError)
+
+	if err := buildCommonFlags(flags, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := flags.Parse([]string{"--config", writeTestManifest(t, testManifest)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyManifest(flags, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6version != "v1.2.0" {
+		t.Errorf("expected k6version v1.2.0, got %s", opts.k6version)
+	}
+
+	if opts.k6repo != "github.com/myfork/k6" {
+		t.Errorf("expected k6repo github.com/myfork/k6, got %s", opts.k6repo)
+	}
+
+	if opts.cgo != 1 {
+		t.Errorf("expected cgo 1, got %d", opts.cgo)
+	}
+
+	if !slices.Equal(opts.buildFlags, []string{"-trimpath"}) {
+		t.Errorf("expected build flags [-trimpath], got %v", opts.buildFlags)
+	}
+
+	if len(opts.extensions.modules) != 2 {
+		t.Fatalf("expected 2 extensions, got %d", len(opts.extensions.modules))
+	}
+}
+
+func TestApplyManifest_FlagsTakePrecedence(t *testing.T) {
+	t.Parallel()
+
+	opts := newBuildOptions()
+	flags := pflag.NewFlagSet("build", pflag.ContinueOnError)
+
+	if err := buildCommonFlags(flags, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	err := flags.Parse([]string{
+		"--config", writeTestManifest(t, testManifest),
+		"--k6-version", "v1.3.0",
+		"--with", "github.com/grafana/xk6-sql@v1.1.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyManifest(flags, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6version != "v1.3.0" {
+		t.Errorf("expected k6version v1.3.0, got %s", opts.k6version)
+	}
+
+	want := []string{"github.com/grafana/xk6-sql@v1.1.0", "github.com/grafana/xk6-faker@v0.4.0"}
+
+	got := make([]string, 0, len(opts.extensions.modules))
+	for _, mod := range opts.extensions.modules {
+		got = append(got, mod.String())
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected extensions %v, got %v", want, got)
+	}
+}
+
+func TestApplyManifest_UnknownField(t *testing.T) {
+	t.Parallel()
+
+	opts := newBuildOptions()
+	flags := pflag.NewFlagSet("build", pflag.ContinueOnError)
+
+	if err := buildCommonFlags(flags, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := flags.Parse([]string{"--config", writeTestManifest(t, "extensions: []\n")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyManifest(flags, opts); err == nil {
+		t.Fatal("expected error for unknown manifest field, got nil")
+	}
+}
+
+func TestApplyManifest_LocalDirectory(t *testing.T) {
+	t.Parallel()
+
+	filename := writeTestManifest(t, "with:\n  - ./xk6-foo\nreplace:\n  - github.com/foo/bar=../bar\n")
+	dir := filepath.Dir(filename)
+
+	err := os.Mkdir(filepath.Join(dir, "xk6-foo"), 0o750) //nolint:forbidigo
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "xk6-foo", "go.mod"), []byte("module github.com/grafana/xk6-foo\n"), 0o600) //nolint:forbidigo,lll
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := newBuildOptions()
+	flags := pflag.NewFlagSet("build", pflag.ContinueOnError)
+
+	if err := buildCommonFlags(flags, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := flags.Parse([]string{"--config", filename}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyManifest(flags, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := opts.extensions.String(); got != "github.com/grafana/xk6-foo => "+filepath.Join(dir, "xk6-foo") {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+
+	if got := opts.replacements.String(); got != "github.com/foo/bar => "+filepath.Join(filepath.Dir(dir), "bar") {
+		t.Errorf("unexpected replacements: %s", got)
+	}
+}
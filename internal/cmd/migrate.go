@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/sync"
+)
+
+//go:embed help/migrate.md
+var migrateHelp string
+
+type migrateOptions struct {
+	to        string
+	k6version string
+	dryRun    bool
+	edit      bool
+	json      bool
+	compact   bool
+}
+
+func migrateCmd() *cobra.Command {
+	opts := new(migrateOptions)
+
+	cmd := &cobra.Command{
+		Use:   "migrate [flags]",
+		Short: shortHelp(migrateHelp),
+		Long:  migrateHelp,
+		Args:  cobra.NoArgs,
+		PreRun: func(_ *cobra.Command, _ []string) {
+			opts.json = opts.json || opts.compact
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return migrateRunE(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), opts)
+		},
+		DisableAutoGenTag: true,
+	}
+
+	cmd.SetContext(context.Background())
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.StringVar(&opts.to, "to", "v2", "The k6 major version to migrate to")
+	flags.StringVarP(&opts.k6version, "k6-version", "k", "",
+		"The k6 version to use. If not specified, uses the latest version of the major version")
+	flags.BoolVarP(&opts.dryRun, "dry-run", "n", false,
+		"Do not make any changes, only log them")
+	flags.BoolVar(&opts.edit, "edit", false,
+		"Edit go.mod and go.sum directly instead of running go get")
+	flags.BoolVar(&opts.json, "json", false,
+		"Generate JSON output")
+	flags.BoolVarP(&opts.compact, "compact", "c", false,
+		"Compact instead of pretty-printed JSON output")
+
+	return cmd
+}
+
+func migrateRunE(ctx context.Context, stdout, stderr io.Writer, opts *migrateOptions) error {
+	result, err := sync.Migrate(ctx, ".", opts.to, &sync.Options{
+		DryRun:    opts.dryRun,
+		Edit:      opts.edit,
+		K6Version: opts.k6version,
+		Stdout:    stdout,
+		Stderr:    stderr,
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.json {
+		return jsonOutput(result, stdout, opts.compact)
+	}
+
+	textMigrateOutput(result, stdout)
+
+	return nil
+}
+
+func textMigrateOutput(result *sync.MigrateResult, output io.Writer) {
+	bold := color.New(color.FgHiWhite, color.Bold).SprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
+	faint := color.New(color.FgBlack).FprintfFunc()
+
+	plain(output, "Migrated from %s to %s %s.\n\n", bold(result.From), bold(result.K6Module), bold(result.K6Version))
+
+	title := "Rewritten imports"
+	plain(output, "%s\n%s\n", title, strings.Repeat("─", utf8.RuneCountInString(title)))
+
+	for _, file := range result.Files {
+		plain(output, "  %s\n", file)
+	}
+
+	faint(output, "  %d imports in %d files\n\n", result.Imports, len(result.Files))
+
+	if result.Sync != nil && len(result.Sync.Changes) != 0 {
+		textChangesOutput(result.Sync.Changes, output)
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/sync"
+)
+
+//go:embed help/outdated.md
+var outdatedHelp string
+
+type outdatedOptions struct {
+	k6version string
+	out       string
+	compact   bool
+	json      bool
+	markdown  bool
+}
+
+func outdatedCmd() *cobra.Command {
+	opts := new(outdatedOptions)
+
+	cmd := &cobra.Command{
+		Use:   "outdated [flags]",
+		Short: shortHelp(outdatedHelp),
+		Long:  outdatedHelp,
+		Args:  cobra.NoArgs,
+		PreRun: func(_ *cobra.Command, _ []string) {
+			opts.json = opts.json || opts.compact
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return outdatedRunE(cmd.Context(), opts)
+		},
+		DisableAutoGenTag: true,
+	}
+
+	cmd.SetContext(context.Background())
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.StringVarP(&opts.k6version, "k6-version", "k", "",
+		"The k6 version to compare with. If not specified, uses the latest release of the k6 major version in go.mod")
+	flags.StringVarP(&opts.out, "out", "o", "",
+		"Write output to file instead of stdout")
+	flags.BoolVar(&opts.json, "json", false,
+		"Generate JSON output")
+	flags.BoolVarP(&opts.compact, "compact", "c", false,
+		"Compact instead of pretty-printed JSON output")
+	flags.BoolVarP(&opts.markdown, "markdown", "m", false,
+		"Generate Markdown output")
+
+	return cmd
+}
+
+func outdatedRunE(ctx context.Context, opts *outdatedOptions) (problem error) {
+	result, err := sync.Outdated(ctx, ".", &sync.Options{K6Version: opts.k6version})
+	if err != nil {
+		return err
+	}
+
+	output := colorable.NewColorableStdout()
+
+	if len(opts.out) > 0 {
+		file, err := os.Create(opts.out) //nolint:forbidigo
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			err := file.Close()
+			if problem == nil && err != nil {
+				problem = err
+			}
+		}()
+
+		output = file
+	}
+
+	if opts.json {
+		return jsonOutput(result, output, opts.compact)
+	}
+
+	if opts.markdown {
+		return markdownOutdatedOutput(result, output)
+	}
+
+	return textOutdatedOutput(result, output)
+}
+
+// upgradeCell returns the version of the upgrade, marked with ! if it diverges from k6, or - if there is no upgrade.
+func upgradeCell(upgrade *sync.Upgrade) string {
+	if upgrade == nil {
+		return "-"
+	}
+
+	if upgrade.Diverges {
+		return upgrade.Version + " !"
+	}
+
+	return upgrade.Version
+}
+
+func k6Cell(dep *sync.Dependency) string {
+	if len(dep.K6) == 0 {
+		return "-"
+	}
+
+	return dep.K6
+}
+
+func outdatedRow(dep *sync.Dependency) []string {
+	return []string{
+		dep.Module, dep.Current, upgradeCell(dep.Patch), upgradeCell(dep.Minor), upgradeCell(dep.Major), k6Cell(dep),
+	}
+}
+
+var outdatedHeader = []string{"MODULE", "CURRENT", "PATCH", "MINOR", "MAJOR", "K6"} //nolint:gochecknoglobals
+
+const outdatedLegend = "! the upgrade diverges from the version required by k6, xk6 sync would undo it"
+
+func textOutdatedOutput(result *sync.OutdatedResult, output io.Writer) error {
+	bold := color.New(color.FgHiWhite, color.Bold).SprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
+	faint := color.New(color.FgBlack).FprintfFunc()
+
+	plain(output, "Direct dependencies compared with %s.\n\n", bold(k6Title(&sync.Result{
+		K6Module:  result.K6Module,
+		K6Version: result.K6Version,
+	})))
+
+	table := tabwriter.NewWriter(output, 0, 0, 3, ' ', 0) //nolint:mnd
+
+	for _, row := range append([][]string{outdatedHeader}, outdatedRows(result)...) {
+		if _, err := fmt.Fprintln(table, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	faint(output, "\n%s\n", outdatedLegend)
+
+	return nil
+}
+
+func outdatedRows(result *sync.OutdatedResult) [][]string {
+	rows := make([][]string, 0, len(result.Dependencies))
+
+	for _, dep := range result.Dependencies {
+		rows = append(rows, outdatedRow(dep))
+	}
+
+	return rows
+}
+
+func markdownOutdatedOutput(result *sync.OutdatedResult, output io.Writer) error {
+	_, err := fmt.Fprintf(output, "Direct dependencies compared with %s.\n\n", k6MarkdownTitle(&sync.Result{
+		K6Module:  result.K6Module,
+		K6Version: result.K6Version,
+	}))
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(output, "| %s |\n", strings.Join(outdatedHeader, " | ")); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(output, "|%s\n", strings.Repeat("---|", len(outdatedHeader))); err != nil {
+		return err
+	}
+
+	for _, row := range outdatedRows(result) {
+		for idx, cell := range row {
+			if cell != "-" {
+				version, diverges := strings.CutSuffix(cell, " !")
+				row[idx] = "`" + version + "`"
+
+				if diverges {
+					row[idx] += " !"
+				}
+			}
+		}
+
+		if _, err := fmt.Fprintf(output, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(output, "\n%s\n", outdatedLegend)
+
+	return err
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"go.k6.io/xk6/internal/sync"
+)
+
+func TestMarkdownOutdatedOutput(t *testing.T) {
+	t.Parallel()
+
+	result := &sync.OutdatedResult{
+		K6Module:  "go.k6.io/k6",
+		K6Version: "v1.2.0",
+		Dependencies: []*sync.Dependency{
+			{
+				Module:  "github.com/foo/bar",
+				Current: "v1.0.0",
+				K6:      "v1.0.0",
+				Minor:   &sync.Upgrade{Module: "github.com/foo/bar", Version: "v1.1.0", Diverges: true},
+			},
+			{
+				Module:  "github.com/baz/qux",
+				Current: "v0.2.0",
+				Patch:   &sync.Upgrade{Module: "github.com/baz/qux", Version: "v0.2.1"},
+			},
+		},
+	}
+
+	var buff bytes.Buffer
+
+	if err := markdownOutdatedOutput(result, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "Direct dependencies compared with k6 `v1.2.0`.\n\n" +
+		"| MODULE | CURRENT | PATCH | MINOR | MAJOR | K6 |\n" +
+		"|---|---|---|---|---|---|\n" +
+		"| `github.com/foo/bar` | `v1.0.0` | - | `v1.1.0` ! | - | `v1.0.0` |\n" +
+		"| `github.com/baz/qux` | `v0.2.0` | `v0.2.1` | - | - | - |\n" +
+		"\n" + outdatedLegend + "\n"
+
+	if buff.String() != expected {
+		t.Errorf("unexpected output:\n%s", buff.String())
+	}
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	goversion "go/version"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	gosync "sync"
+	"text/template"
+
+	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/inspect"
+	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+const (
+	allPlatforms          = "all"
+	defaultPlatformOutput = "dist/k6-{{.OS}}-{{.Arch}}{{.Ext}}"
+	// zeroPseudoVersion is the version the go command uses for requirements satisfied by a replacement.
+	zeroPseudoVersion = "v0.0.0-00010101000000-000000000000"
+)
+
+var (
+	errDuplicateOutput = errors.New("output template must produce a distinct filename for each platform")
+	errARMPlatform     = errors.New("the ARM version only applies to linux/arm targets")
+)
+
+// buildTarget is a single platform of a matrix build.
+type buildTarget struct {
+	platform k6foundry.Platform
+	output   string
+}
+
+// outputData is the data available in the output filename template.
+type outputData struct {
+	OS   string
+	Arch string
+	Ext  string
+}
+
+// parsePlatforms converts the values of the platform flag into a list of platforms.
+// The special value "all" stands for every platform supported by k6foundry.
+func parsePlatforms(values []string) ([]k6foundry.Platform, error) {
+	platforms := make([]k6foundry.Platform, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if value == allPlatforms {
+			for _, platform := range k6foundry.SupportedPlatforms() {
+				if !slices.Contains(platforms, platform) {
+					platforms = append(platforms, platform)
+				}
+			}
+
+			continue
+		}
+
+		platform, err := k6foundry.ParsePlatform(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(platforms, platform) {
+			platforms = append(platforms, platform)
+		}
+	}
+
+	return platforms, nil
+}
+
+// renderOutput renders the output filename template for the given platform.
+func renderOutput(tmpl string, platform k6foundry.Platform) (string, error) {
+	t, err := template.New("output").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	data := outputData{OS: platform.OS, Arch: platform.Arch}
+	if platform.OS == "windows" {
+		data.Ext = ".exe"
+	}
+
+	var buff bytes.Buffer
+
+	if err := t.Execute(&buff, data); err != nil {
+		return "", err
+	}
+
+	return buff.String(), nil
+}
+
+func buildTargets(opts *buildOptions) ([]buildTarget, error) {
+	platforms, err := parsePlatforms(opts.platforms)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.arm) != 0 && !slices.ContainsFunc(platforms, isARM) {
+		return nil, fmt.Errorf("%w: %s", errARMPlatform, strings.Join(opts.platforms, ","))
+	}
+
+	targets := make([]buildTarget, 0, len(platforms))
+	outputs := make(map[string]struct{}, len(platforms))
+
+	for _, platform := range platforms {
+		output, err := renderOutput(opts.output, platform)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, found := outputs[output]; found {
+			return nil, fmt.Errorf("%w: %s", errDuplicateOutput, output)
+		}
+
+		outputs[output] = struct{}{}
+
+		targets = append(targets, buildTarget{platform: platform, output: output})
+	}
+
+	return targets, nil
+}
+
+func isARM(platform k6foundry.Platform) bool {
+	return platform.OS == "linux" && platform.Arch == "arm"
+}
+
+// buildK6Matrix builds k6 for every target using at most opts.parallel concurrent builds.
+// The versions and the k6 module are resolved only once, before the builds are started. The first
+// target is built alone, then the module versions compiled into it are pinned for the other targets,
+// so every target is built with the same module versions without resolving the module graph again.
+// The first failure cancels the builds still running.
+func buildK6Matrix(ctx context.Context, opts *buildOptions, targets []buildTarget) ([]*k6foundry.BuildInfo, error) {
+	if err := resolveVersions(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	if err := resolveK6Repo(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	infos := make([]*k6foundry.BuildInfo, len(targets))
+
+	info, err := buildK6Target(ctx, opts, targets[0])
+	if err != nil {
+		return nil, err
+	}
+
+	infos[0] = info
+
+	popts := *opts
+
+	// the modules of a locked build are pinned already
+	if len(opts.pins) == 0 {
+		popts.pins, err = matrixPins(ctx, opts, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	workers := opts.parallel
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       gosync.WaitGroup
+		mu       gosync.Mutex
+		firstErr error
+	)
+
+	sem := make(chan struct{}, workers)
+
+	for idx, target := range targets[1:] {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			info, err := buildK6Target(ctx, &popts, target)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+
+					cancel()
+				}
+				mu.Unlock()
+
+				return
+			}
+
+			infos[idx+1] = info
+		})
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return infos, nil
+}
+
+// buildK6Target builds k6 for a target of a matrix build.
+func buildK6Target(ctx context.Context, opts *buildOptions, target buildTarget) (*k6foundry.BuildInfo, error) {
+	topts := *opts
+	topts.os = target.platform.OS
+	topts.arch = target.platform.Arch
+	topts.output = target.output
+	topts.buildFlags = slices.Clone(opts.buildFlags)
+
+	if !isARM(target.platform) {
+		topts.arm = ""
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target.output), 0o750); err != nil { //nolint:forbidigo
+		return nil, err
+	}
+
+	slog.Debug("Building k6", "platform", target.platform, "output", target.output)
+
+	info, err := buildK6Binary(ctx, &topts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", target.platform, err)
+	}
+
+	return info, nil
+}
+
+// matrixPins returns the modules of the module graph of the build module, except k6, the extensions and
+// the replaced modules (which are pinned by the build options), replaced with themselves at the selected
+// version. The whole module graph is pinned, not only the modules compiled into the first binary, so the
+// modules only linked on other platforms resolve to the same versions too.
+func matrixPins(ctx context.Context, opts *buildOptions, info *k6foundry.BuildInfo) ([]k6foundry.Module, error) {
+	mf, err := matrixModfile(ctx, opts, info)
+	if err != nil {
+		return nil, err
+	}
+
+	mods, err := sync.BuildList(ctx, mf)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make(map[string]struct{})
+
+	for _, mod := range slices.Concat(opts.extensions.modules, opts.replacements.modules) {
+		pinned[mod.Path] = struct{}{}
+	}
+
+	pins := make([]k6foundry.Module, 0, len(mods))
+
+	for _, mod := range mods {
+		if _, found := pinned[mod.Path]; found || inspect.IsK6Module(mod.Path) {
+			continue
+		}
+
+		pins = append(pins, k6foundry.Module{Path: mod.Path, ReplacePath: mod.Path, ReplaceVersion: mod.Version})
+	}
+
+	return pins, nil
+}
+
+// matrixModfile returns the go.mod of a module requiring k6 and the extensions at the versions resolved by the
+// first build, with the replacements of the build, which has the same module graph as the build module.
+func matrixModfile(ctx context.Context, opts *buildOptions, info *k6foundry.BuildInfo) (*modfile.File, error) {
+	mf := new(modfile.File)
+
+	if err := mf.AddModuleStmt("k6"); err != nil {
+		return nil, err
+	}
+
+	// like go mod init in the build module, the go version of the toolchain enables module graph pruning
+	if goVersion, err := goToolchainVersion(ctx); err == nil && len(goversion.Lang(goVersion)) != 0 {
+		if err := mf.AddGoStmt(strings.TrimPrefix(goversion.Lang(goVersion), "go")); err != nil {
+			return nil, err
+		}
+	}
+
+	k6 := k6foundry.Module{Path: info.K6ModPath, Version: info.ModVersions[info.K6ModPath]}
+
+	// forks are built by replacing the k6 module, its version is the version of the fork
+	if base, _, _ := module.SplitPathVersion(opts.k6repo); base != defaultK6Repo {
+		k6 = k6foundry.Module{Path: info.K6ModPath, ReplacePath: opts.k6repo, ReplaceVersion: k6.Version}
+	}
+
+	for _, mod := range slices.Concat([]k6foundry.Module{k6}, opts.extensions.modules) {
+		if len(mod.ReplacePath) != 0 {
+			if err := addMatrixReplace(mf, mod); err != nil {
+				return nil, err
+			}
+
+			if len(mod.Version) == 0 {
+				mod.Version = zeroPseudoVersion
+			}
+		} else if version, found := info.ModVersions[mod.Path]; found {
+			mod.Version = version
+		}
+
+		if err := mf.AddRequire(mod.Path, mod.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, mod := range opts.replacements.modules {
+		if err := addMatrixReplace(mf, mod); err != nil {
+			return nil, err
+		}
+	}
+
+	return mf, nil
+}
+
+// addMatrixReplace adds the replacement of the module. Like in the build module, relative
+// directory paths are relative to the current directory.
+func addMatrixReplace(mf *modfile.File, mod k6foundry.Module) error {
+	path := mod.ReplacePath
+
+	if modfile.IsDirectoryPath(path) && !filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		path = abs
+	}
+
+	return mf.AddReplace(mod.Path, mod.Version, path, mod.ReplaceVersion)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grafana/k6foundry"
+)
+
+func TestParsePlatforms_All(t *testing.T) {
+	t.Parallel()
+
+	platforms, err := parsePlatforms([]string{"linux/amd64", "all"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(platforms) != len(k6foundry.SupportedPlatforms()) {
+		t.Errorf("expected %d platforms, got %d", len(k6foundry.SupportedPlatforms()), len(platforms))
+	}
+}
+
+func TestParsePlatforms_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := parsePlatforms([]string{"plan9/mips"})
+	if !errors.Is(err, k6foundry.ErrInvalidPlatform) {
+		t.Errorf("expected ErrInvalidPlatform, got %v", err)
+	}
+}
+
+func TestRenderOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		platform k6foundry.Platform
+		want     string
+	}{
+		{k6foundry.Platform{OS: "linux", Arch: "amd64"}, "dist/k6-linux-amd64"},
+		{k6foundry.Platform{OS: "windows", Arch: "arm64"}, "dist/k6-windows-arm64.exe"},
+	}
+
+	for _, tt := range tests {
+		got, err := renderOutput(defaultPlatformOutput, tt.platform)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestBuildTargets_DuplicateOutput(t *testing.T) {
+	t.Parallel()
+
+	opts := newBuildOptions()
+	opts.output = "dist/k6-{{.OS}}"
+	opts.platforms = []string{"linux/amd64", "linux/arm64"}
+
+	_, err := buildTargets(opts)
+	if !errors.Is(err, errDuplicateOutput) {
+		t.Errorf("expected errDuplicateOutput, got %v", err)
+	}
+}
+
+func TestBuildTargets_ARM(t *testing.T) {
+	t.Parallel()
+
+	opts := newBuildOptions()
+	opts.output = defaultPlatformOutput
+	opts.platforms = []string{"linux/amd64", "linux/arm64"}
+	opts.arm = "7"
+
+	_, err := buildTargets(opts)
+	if !errors.Is(err, errARMPlatform) {
+		t.Errorf("expected errARMPlatform, got %v", err)
+	}
+}
+
+// newFileProxy serves the go.mod files of the module versions (path@version) from a file:// Go proxy.
+func newFileProxy(t *testing.T, mods map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for key, require := range mods {
+		path, version, _ := strings.Cut(key, "@")
+
+		versions := filepath.Join(dir, filepath.FromSlash(path), "@v")
+		if err := os.MkdirAll(versions, 0o750); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+
+		files := map[string]string{
+			version + ".mod":  "module " + path + "\n\ngo 1.21\n" + require,
+			version + ".info": `{"Version":"` + version + `"}`,
+		}
+
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(versions, name), []byte(data), 0o600); err != nil { //nolint:forbidigo
+				t.Fatal(err)
+			}
+		}
+	}
+
+	proxy := filepath.ToSlash(dir)
+	if !strings.HasPrefix(proxy, "/") {
+		proxy = "/" + proxy // windows drive letter
+	}
+
+	t.Setenv("GOPROXY", "file://"+proxy)
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOTOOLCHAIN", "local")
+}
+
+func TestMatrixPins(t *testing.T) {
+	newFileProxy(t, map[string]string{
+		"go.k6.io/k6@v1.0.0": "require (\n\texample.com/lib v1.0.0\n\texample.com/replaced v1.0.0\n" +
+			"\texample.com/windows v1.0.0\n)\n",
+		"github.com/grafana/xk6-foo@v0.1.0": "require (\n\texample.com/lib v1.1.0\n\tgo.k6.io/k6 v1.0.0\n)\n",
+		"example.com/lib@v1.0.0":            "",
+		"example.com/lib@v1.1.0":            "",
+		"example.com/replaced@v1.0.0":       "",
+		"example.com/fork@v1.2.0":           "",
+		"example.com/windows@v1.0.0":        "",
+	})
+
+	opts := newBuildOptions()
+	opts.extensions.modules = []k6foundry.Module{{Path: "github.com/grafana/xk6-foo"}}
+	opts.replacements.modules = []k6foundry.Module{
+		{Path: "example.com/replaced", ReplacePath: "example.com/fork", ReplaceVersion: "v1.2.0"},
+	}
+
+	info := &k6foundry.BuildInfo{
+		K6ModPath:   "go.k6.io/k6",
+		ModVersions: map[string]string{"go.k6.io/k6": "v1.0.0", "github.com/grafana/xk6-foo": "v0.1.0"},
+	}
+
+	pins, err := matrixPins(t.Context(), opts, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the whole module graph is pinned, including the modules only compiled on other platforms
+	expected := []k6foundry.Module{
+		{Path: "example.com/lib", ReplacePath: "example.com/lib", ReplaceVersion: "v1.1.0"},
+		{Path: "example.com/windows", ReplacePath: "example.com/windows", ReplaceVersion: "v1.0.0"},
+	}
+
+	if !reflect.DeepEqual(pins, expected) {
+		t.Errorf("expected pins %v, got %v", expected, pins)
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/szkiba/efa"
+	"go.k6.io/xk6/internal/modproxy"
+)
+
+//go:embed help/prefetch.md
+var prefetchHelp string
+
+var errMissingProxyDir = errors.New("the --proxy-dir flag is required")
+
+const dirPerm = 0o750
+
+func prefetchCmd() *cobra.Command {
+	opts := newBuildOptions()
+
+	cmd := &cobra.Command{
+		Use:   "prefetch [flags] [k6-version]",
+		Short: shortHelp(prefetchHelp),
+		Long:  prefetchHelp,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			k6v := cmd.Flags().Lookup("k6-version")
+
+			if k6v.Changed && len(args) != 0 {
+				return errArgumentAndFlag
+			}
+
+			if len(args) > 0 {
+				if err := cmd.Flags().Set("k6-version", args[0]); err != nil {
+					return err
+				}
+			}
+
+			if err := applyManifest(cmd.Flags(), opts); err != nil {
+				return err
+			}
+
+			if len(opts.proxyDir) == 0 {
+				return errMissingProxyDir
+			}
+
+			return prefetchRunE(opts.prerelease.context(cmd.Context()), cmd.OutOrStdout(), opts)
+		},
+		DisableAutoGenTag: true,
+	}
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.StringVar(&opts.proxyDir, "proxy-dir", "", "The directory to download the modules into (Go proxy layout)")
+
+	cobra.CheckErr(buildCommonFlags(flags, opts))
+
+	env := efa.New(flags, appname, nil)
+
+	cobra.CheckErr(env.Bind("proxy-dir"))
+
+	return cmd
+}
+
+// prefetchRunE builds k6 with a private, empty Go module cache, so that every module required
+// by the build is downloaded. The downloaded modules are then exported to the proxy directory.
+func prefetchRunE(ctx context.Context, stdout io.Writer, opts *buildOptions) error {
+	tmpdir, err := os.MkdirTemp("", "xk6-prefetch-*") //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = os.RemoveAll(tmpdir) //nolint:forbidigo
+	}()
+
+	modcache := filepath.Join(tmpdir, "mod")
+
+	opts.output = filepath.Join(tmpdir, "k6")
+	if opts.os == "windows" {
+		opts.output += ".exe"
+	}
+
+	// the module cache is read-only by default, so it could not be removed
+	goflags := strings.TrimSpace(os.Getenv("GOFLAGS") + " -modcacherw") //nolint:forbidigo
+
+	err = withEnv(map[string]string{"GOMODCACHE": modcache, "GOFLAGS": goflags}, func() error {
+		_, berr := buildK6(ctx, opts)
+
+		return berr
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.proxyDir, dirPerm); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	count, err := modproxy.Export(filepath.Join(modcache, "cache", "download"), opts.proxyDir)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Modules prefetched", "count", count, "dir", opts.proxyDir)
+
+	_, _ = fmt.Fprintf(stdout, prefetchMessageFmt, opts.proxyDir)
+
+	return nil
+}
+
+const prefetchMessageFmt = `
+The modules required by the build have been downloaded.
+Build k6 without network access using 'xk6 build --offline --proxy-dir %v' with the same settings.
+`
+
+// offlineEnv returns the Go environment of an offline build, which uses the proxy directory
+// as the only source of modules and does not contact the checksum database.
+func offlineEnv(proxyDir string) (map[string]string, error) {
+	proxy, err := modproxy.URL(proxyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"GOPROXY":   proxy,
+		"GOSUMDB":   "off",
+		"GONOPROXY": "",
+		"GOPRIVATE": "",
+		"GONOSUMDB": "",
+	}, nil
+}
+
+// withEnv runs fn with the given environment variables set in the process environment.
+// The environment is used by both the go command (through k6foundry) and the Go proxy
+// requests made by xk6 itself. The previous values are restored afterwards.
+func withEnv(vars map[string]string, fn func() error) error {
+	for key, value := range vars {
+		prev, found := os.LookupEnv(key) //nolint:forbidigo
+
+		if err := os.Setenv(key, value); err != nil { //nolint:forbidigo
+			return err
+		}
+
+		defer func() {
+			if found {
+				_ = os.Setenv(key, prev) //nolint:forbidigo
+			} else {
+				_ = os.Unsetenv(key) //nolint:forbidigo
+			}
+		}()
+	}
+
+	slog.Debug("Build environment", "env", vars)
+
+	return fn()
+}
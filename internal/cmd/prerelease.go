@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+	"github.com/szkiba/efa"
+	"go.k6.io/xk6/internal/sync"
+)
+
+// prereleasePolicy is the value of the --prerelease flag.
+type prereleasePolicy sync.PrereleasePolicy
+
+func (p *prereleasePolicy) String() string {
+	return string(*p)
+}
+
+func (p *prereleasePolicy) Set(v string) error {
+	policy, err := sync.ParsePrereleasePolicy(v)
+	if err != nil {
+		return err
+	}
+
+	*p = prereleasePolicy(policy)
+
+	return nil
+}
+
+func (p *prereleasePolicy) Type() string {
+	return "policy"
+}
+
+// context returns a context resolving the latest versions with the pre-release policy.
+func (p *prereleasePolicy) context(ctx context.Context) context.Context {
+	return sync.WithPrereleasePolicy(ctx, sync.PrereleasePolicy(*p))
+}
+
+// prereleaseFlag defines the --prerelease flag, bound to the XK6_PRERELEASE environment variable.
+func prereleaseFlag(flags *pflag.FlagSet, policy *prereleasePolicy) error {
+	*policy = prereleasePolicy(sync.PrereleaseAuto)
+
+	flags.Var(policy, "prerelease", "Pre-release policy when resolving the latest versions (auto, include or exclude)")
+
+	env := efa.New(flags, appname, nil)
+
+	return env.Bind("prerelease")
+}
@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/inspect"
+	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/semver"
+)
+
+//go:embed help/rebuild.md
+var rebuildHelp string
+
+type rebuildOptions struct {
+	output            string
+	k6version         string
+	upgradeExtensions bool
+	json              bool
+	compact           bool
+	markdown          bool
+}
+
+func rebuildCmd() *cobra.Command {
+	ropts := new(rebuildOptions)
+
+	cmd := &cobra.Command{
+		Use:   "rebuild [flags] binary",
+		Short: shortHelp(rebuildHelp),
+		Long:  rebuildHelp,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(ropts.output) == 0 {
+				ropts.output = args[0]
+			}
+
+			return rebuildRunE(cmd.Context(), args[0], cmd.OutOrStdout(), ropts)
+		},
+		DisableAutoGenTag: true,
+	}
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.StringVarP(&ropts.output, "output", "o", "", "Output filename (default: the original binary)")
+	flags.StringVarP(&ropts.k6version, "k6-version", "k", "", "The k6 version to use (default: the version of the original binary)")
+	flags.BoolVar(&ropts.upgradeExtensions, "upgrade-extensions", false, "Upgrade the extensions to their latest version")
+	flags.BoolVar(&ropts.json, "json", false, "Generate JSON output")
+	flags.BoolVarP(&ropts.compact, "compact", "c", false, "Compact instead of pretty-printed JSON output")
+	flags.BoolVarP(&ropts.markdown, "markdown", "m", false, "Generate Markdown output")
+
+	return cmd
+}
+
+func rebuildRunE(ctx context.Context, binary string, stdout io.Writer, ropts *rebuildOptions) error {
+	orig, err := inspect.Inspect(ctx, binary, sync.ModuleRequirements)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(binary) //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	opts, err := rebuildBuildOptions(ctx, orig, ropts)
+	if err != nil {
+		return err
+	}
+
+	// The binary is built next to the output and renamed then, so a running
+	// or failed build never leaves a partially written binary behind.
+	tmp, err := os.CreateTemp(filepath.Dir(ropts.output), "."+filepath.Base(ropts.output)+"-*") //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	opts.output = tmp.Name()
+
+	_ = tmp.Close()
+
+	defer func() {
+		_ = os.Remove(opts.output) //nolint:forbidigo
+	}()
+
+	if _, err := buildK6(ctx, opts); err != nil {
+		return err
+	}
+
+	// the rebuilt binary keeps the file mode of the original one
+	if err := os.Chmod(opts.output, info.Mode().Perm()); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	if err := os.Rename(opts.output, ropts.output); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	slog.Info("Successful rebuild", "output", ropts.output)
+
+	rebuilt, err := inspect.Inspect(ctx, ropts.output, sync.ModuleRequirements)
+	if err != nil {
+		return err
+	}
+
+	result := &sync.Result{K6Version: rebuilt.K6.Version, Changes: compositionChanges(orig, rebuilt)}
+
+	if ropts.json || ropts.compact {
+		return jsonOutput(result, stdout, ropts.compact)
+	}
+
+	if ropts.markdown {
+		return markdownRebuildOutput(result, stdout)
+	}
+
+	textRebuildOutput(result, stdout)
+
+	return nil
+}
+
+// rebuildBuildOptions reconstructs the build options of the original binary, applying the requested upgrades.
+func rebuildBuildOptions(ctx context.Context, orig *inspect.Composition, ropts *rebuildOptions) (*buildOptions, error) {
+	opts := newBuildOptions()
+
+	opts.k6repo, opts.k6version = orig.K6.Path, orig.K6.Version
+
+	// forks are built by replacing the k6 module
+	if len(orig.K6.Replace) != 0 {
+		repo, version, found := strings.Cut(orig.K6.Replace, "@")
+
+		opts.k6repo = repo
+		if found {
+			opts.k6version = version
+		}
+	}
+
+	if len(ropts.k6version) != 0 {
+		opts.k6version = ropts.k6version
+
+		// let the usual resolution pick the module path (and major version) of the new k6 version
+		if inspect.IsK6Module(opts.k6repo) {
+			opts.k6repo = defaultK6Repo
+		}
+	}
+
+	k6version, err := rebuildK6Version(ctx, orig, opts, ropts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ext := range orig.Extensions {
+		value := ext.String()
+
+		if ropts.upgradeExtensions && len(ext.Replace) == 0 {
+			version, err := sync.ResolveCompatibleVersion(ctx, ext.Path, k6version)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ext.Path, err)
+			}
+
+			// an upgrade never downgrades an extension
+			if semver.Compare(version, ext.Version) < 0 {
+				slog.Debug("Keeping extension version, no newer compatible version",
+					"module", ext.Path, "version", ext.Version, "compatible", version)
+
+				version = ext.Version
+			}
+
+			value = ext.Path + "@" + version
+		}
+
+		if err := opts.extensions.Set(value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, rep := range orig.Replacements {
+		if err := opts.replacements.Set(rep.Path + "=" + rep.Replace); err != nil {
+			return nil, err
+		}
+	}
+
+	opts.os, opts.arch, opts.arm = orig.OS, orig.Arch, orig.ARM
+	opts.buildFlags = slices.Clone(orig.BuildFlags)
+
+	if orig.Cgo {
+		opts.cgo = 1
+	}
+
+	if orig.RaceDetector {
+		opts.raceDetector = 1
+	}
+
+	return opts, nil
+}
+
+// rebuildK6Version returns the exact k6 version the extensions are upgraded for: the requested k6 version
+// resolved to a release, or the k6 version of the original binary. The build is pinned to the resolved
+// release, otherwise latest would be resolved to the k6 version required by the (kept) extensions.
+// The version of the k6 module (not of a fork) is used, as it is the one required by the extensions.
+func rebuildK6Version(
+	ctx context.Context, orig *inspect.Composition, opts *buildOptions, ropts *rebuildOptions,
+) (string, error) {
+	if len(ropts.k6version) == 0 {
+		return orig.K6.Version, nil
+	}
+
+	var (
+		path, version string
+		err           error
+	)
+
+	switch {
+	case semver.IsValid(opts.k6version):
+		return opts.k6version, nil
+	case sync.IsConstraint(opts.k6version):
+		path, version, err = sync.ResolveConstraint(ctx, opts.k6repo, opts.k6version)
+	case opts.k6version == defaultK6Version:
+		path, version, err = sync.GetOverallLatestVersionFor(ctx, opts.k6repo)
+	default:
+		path = opts.k6repo
+		version, err = sync.ResolveVersion(ctx, opts.k6repo, opts.k6version)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("k6: %w", err)
+	}
+
+	opts.k6repo, opts.k6version = path, version
+
+	return version, nil
+}
+
+// compositionChanges returns the k6, extension and replacement changes between two builds.
+// Added modules have no from version, removed modules have no to version.
+func compositionChanges(from, to *inspect.Composition) []*sync.Change {
+	changes := make([]*sync.Change, 0)
+
+	if from.K6 != to.K6 {
+		changes = append(changes, &sync.Change{
+			Module: to.K6.Path,
+			From:   moduleVersion(from.K6),
+			To:     moduleVersion(to.K6),
+		})
+	}
+
+	changes = append(changes, moduleChanges(from.Extensions, to.Extensions)...)
+
+	return append(changes, moduleChanges(from.Replacements, to.Replacements)...)
+}
+
+// moduleChanges returns the changed, added and removed modules between two module lists.
+func moduleChanges(from, to []inspect.Module) []*sync.Change {
+	changes := make([]*sync.Change, 0)
+
+	for _, mod := range to {
+		idx := slices.IndexFunc(from, func(m inspect.Module) bool { return m.Path == mod.Path })
+		if idx < 0 {
+			changes = append(changes, &sync.Change{Module: mod.Path, To: moduleVersion(mod)})
+
+			continue
+		}
+
+		if prev := from[idx]; prev != mod {
+			changes = append(changes, &sync.Change{Module: mod.Path, From: moduleVersion(prev), To: moduleVersion(mod)})
+		}
+	}
+
+	for _, mod := range from {
+		if !slices.ContainsFunc(to, func(m inspect.Module) bool { return m.Path == mod.Path }) {
+			changes = append(changes, &sync.Change{Module: mod.Path, From: moduleVersion(mod)})
+		}
+	}
+
+	return changes
+}
+
+// moduleVersion returns the effective version of the module, taking the replacement into account.
+func moduleVersion(mod inspect.Module) string {
+	if _, version, found := strings.Cut(mod.Replace, "@"); found {
+		return version
+	}
+
+	return mod.Version
+}
+
+func textRebuildOutput(result *sync.Result, output io.Writer) {
+	bold := color.New(color.FgHiWhite, color.Bold).SprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
+
+	plain(output, "The binary has been rebuilt with k6 %s.\n\n", bold(result.K6Version))
+
+	if len(result.Changes) == 0 {
+		plain(output, "No module versions have changed.\n")
+
+		return
+	}
+
+	textChangesOutput(result.Changes, output)
+}
+
+func markdownRebuildOutput(result *sync.Result, output io.Writer) error {
+	_, err := fmt.Fprintf(output, "The binary has been rebuilt with k6 `%s`.\n\n", result.K6Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(output, "**Changes**\n\n")
+	if err != nil {
+		return err
+	}
+
+	for _, change := range result.Changes {
+		_, err = fmt.Fprintf(output, "- %s\n  `%s` => `%s`\n", change.Module, fromVersion(change), toVersion(change))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
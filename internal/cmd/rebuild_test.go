@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.k6.io/xk6/internal/inspect"
+)
+
+func testComposition() *inspect.Composition {
+	return &inspect.Composition{
+		K6: inspect.Module{Path: "go.k6.io/k6", Version: "v1.1.0"},
+		Extensions: []inspect.Module{
+			{Path: "github.com/grafana/xk6-faker", Version: "v0.4.0"},
+			{Path: "github.com/grafana/xk6-local", Version: "v0.0.0", Replace: "/src/xk6-local"},
+		},
+		Replacements: []inspect.Module{{Path: "github.com/foo/bar", Version: "v1.0.0", Replace: "github.com/baz/bar@v1.0.1"}},
+		OS:           "linux",
+		Arch:         "arm64",
+		Cgo:          true,
+		BuildFlags:   []string{"-trimpath"},
+	}
+}
+
+func TestRebuildBuildOptions_Keep(t *testing.T) {
+	t.Parallel()
+
+	opts, err := rebuildBuildOptions(t.Context(), testComposition(), new(rebuildOptions))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6repo != "go.k6.io/k6" || opts.k6version != "v1.1.0" {
+		t.Errorf("unexpected k6: %s@%s", opts.k6repo, opts.k6version)
+	}
+
+	expected := "github.com/grafana/xk6-faker@v0.4.0,github.com/grafana/xk6-local => /src/xk6-local"
+	if got := opts.extensions.String(); got != expected {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+
+	if got := opts.replacements.String(); got != "github.com/foo/bar => github.com/baz/bar@v1.0.1" {
+		t.Errorf("unexpected replacements: %s", got)
+	}
+
+	if opts.os != "linux" || opts.arch != "arm64" || opts.cgo != 1 || opts.raceDetector != 0 {
+		t.Errorf("unexpected build settings: %+v", opts)
+	}
+}
+
+func TestRebuildBuildOptions_Upgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/grafana/xk6-faker/@v/list":
+			_, _ = fmt.Fprintf(w, "v0.4.0\nv0.5.0\nv0.6.0\n")
+		case "/github.com/grafana/xk6-faker/@v/v0.6.0.mod":
+			_, _ = fmt.Fprintf(w, "module github.com/grafana/xk6-faker\n\nrequire go.k6.io/k6 v1.3.0\n")
+		case "/github.com/grafana/xk6-faker/@v/v0.5.0.mod":
+			_, _ = fmt.Fprintf(w, "module github.com/grafana/xk6-faker\n\nrequire go.k6.io/k6 v1.2.0\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("GOPROXY", srv.URL)
+
+	opts, err := rebuildBuildOptions(t.Context(), testComposition(), &rebuildOptions{k6version: "v1.2.0", upgradeExtensions: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6repo != "go.k6.io/k6" || opts.k6version != "v1.2.0" {
+		t.Errorf("unexpected k6: %s@%s", opts.k6repo, opts.k6version)
+	}
+
+	// v0.6.0 is the latest version, but it requires a newer k6
+	expected := "github.com/grafana/xk6-faker@v0.5.0,github.com/grafana/xk6-local => /src/xk6-local"
+	if got := opts.extensions.String(); got != expected {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+}
+
+func TestRebuildBuildOptions_LatestK6(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go.k6.io/k6/@v/list":
+			_, _ = fmt.Fprintf(w, "v1.1.0\nv1.3.0\n")
+		case "/go.k6.io/k6/@latest":
+			_, _ = fmt.Fprintf(w, `{"Version":"v1.3.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("GOPROXY", srv.URL)
+
+	// the build is pinned to the latest k6, not to the k6 version required by the kept extensions
+	opts, err := rebuildBuildOptions(t.Context(), testComposition(), &rebuildOptions{k6version: "latest"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.k6repo != "go.k6.io/k6" || opts.k6version != "v1.3.0" {
+		t.Errorf("unexpected k6: %s@%s", opts.k6repo, opts.k6version)
+	}
+
+	if got := opts.extensions.String(); got != "github.com/grafana/xk6-faker@v0.4.0,github.com/grafana/xk6-local => /src/xk6-local" {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+}
+
+func TestRebuildBuildOptions_NoDowngrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/grafana/xk6-faker/@v/list":
+			_, _ = fmt.Fprintf(w, "v0.3.0\nv0.4.0\n")
+		case "/github.com/grafana/xk6-faker/@v/v0.4.0.mod":
+			_, _ = fmt.Fprintf(w, "module github.com/grafana/xk6-faker\n\nrequire go.k6.io/k6 v1.3.0\n")
+		case "/github.com/grafana/xk6-faker/@v/v0.3.0.mod":
+			_, _ = fmt.Fprintf(w, "module github.com/grafana/xk6-faker\n\nrequire go.k6.io/k6 v1.0.0\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("GOPROXY", srv.URL)
+
+	opts, err := rebuildBuildOptions(t.Context(), testComposition(), &rebuildOptions{upgradeExtensions: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// v0.3.0 is the newest version compatible with k6 v1.1.0, but it is older than the current one
+	if got := opts.extensions.String(); got != "github.com/grafana/xk6-faker@v0.4.0,github.com/grafana/xk6-local => /src/xk6-local" {
+		t.Errorf("unexpected extensions: %s", got)
+	}
+}
+
+func TestCompositionChanges(t *testing.T) {
+	t.Parallel()
+
+	from := testComposition()
+	to := testComposition()
+
+	if changes := compositionChanges(from, to); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	to.K6 = inspect.Module{Path: "go.k6.io/k6/v2", Version: "v2.0.0"}
+	to.Extensions = []inspect.Module{
+		{Path: "github.com/grafana/xk6-faker", Version: "v0.5.0"},
+		{Path: "github.com/grafana/xk6-sql", Version: "v1.0.0"},
+	}
+
+	changes := compositionChanges(from, to)
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d", len(changes))
+	}
+
+	if c := changes[2]; c.Module != "github.com/grafana/xk6-sql" || c.From != "" || c.To != "v1.0.0" {
+		t.Errorf("unexpected added extension: %+v", c)
+	}
+
+	if c := changes[3]; c.Module != "github.com/grafana/xk6-local" || c.From != "v0.0.0" || c.To != "" {
+		t.Errorf("unexpected removed extension: %+v", c)
+	}
+
+	if c := changes[0]; c.Module != "go.k6.io/k6/v2" || c.From != "v1.1.0" || c.To != "v2.0.0" {
+		t.Errorf("unexpected k6 change: %+v", c)
+	}
+
+	if c := changes[1]; c.Module != "github.com/grafana/xk6-faker" || c.From != "v0.4.0" || c.To != "v0.5.0" {
+		t.Errorf("unexpected extension change: %+v", c)
+	}
+}
@@ -95,7 +95,7 @@ func New(levelVar *slog.LevelVar) *cobra.Command {
 
 	root.MarkFlagsMutuallyExclusive("quiet", "verbose")
 
-	root.AddCommand(versionCmd(), newCmd(), buildCmd(), runCmd(), xCmd(), lintCmd(), testCmd(), syncCmd())
+	root.AddCommand(versionCmd(), newCmd(), buildCmd(), runCmd(), xCmd(), lintCmd(), testCmd(), syncCmd(), cacheCmd(), inspectCmd(), rebuildCmd(), prefetchCmd(), migrateCmd(), outdatedCmd(), versionsCmd())
 	root.AddCommand(helpTopics()...)
 
 	cmd := adjustCmd()
@@ -17,7 +17,11 @@ func runCmd() *cobra.Command {
 		Short: shortHelp(runHelp),
 		Long:  runHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runK6Command(cmd.Context(), opts, "run", args)
+			if err := applyManifest(cmd.Flags(), opts); err != nil {
+				return err
+			}
+
+			return runK6Command(opts.prerelease.context(cmd.Context()), opts, "run", args)
 		},
 		DisableAutoGenTag: true,
 	}
@@ -27,6 +31,7 @@ func runCmd() *cobra.Command {
 	flags.SortFlags = false
 
 	cobra.CheckErr(buildCommonFlags(flags, opts))
+	cobra.CheckErr(onTheFlyFlags(flags, opts))
 
 	return cmd
 }
@@ -3,11 +3,14 @@ package cmd
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 
 	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/cache"
 	"golang.org/x/mod/modfile"
 )
 
@@ -40,38 +43,129 @@ func runK6Command(ctx context.Context, opts *buildOptions, k6cmd string, args []
 }
 
 func buildK6OnTheFly(ctx context.Context, opts *buildOptions) (func(), error) {
-	dir, err := os.MkdirTemp("", "xk6-build-*") //nolint:forbidigo
+	mfile, moddir, err := getModfile()
 	if err != nil {
 		return nil, err
 	}
 
-	mfile, moddir, err := getModfile()
-	if err != nil {
+	// The extension may already be given as a local directory (e.g. --with .).
+	if !slices.ContainsFunc(opts.extensions.modules, func(mod k6foundry.Module) bool {
+		return mod.Path == mfile.Module.Mod.Path
+	}) {
+		opts.extensions.modules = append(
+			opts.extensions.modules,
+			k6foundry.Module{Path: mfile.Module.Mod.Path, ReplacePath: moddir},
+		)
+	}
+
+	for _, rep := range mfile.Replace {
+		opts.replacements.modules = append(opts.replacements.modules, modfileReplacement(rep, moddir))
+	}
+
+	if err := resolveVersions(ctx, opts); err != nil {
 		return nil, err
 	}
 
-	opts.extensions.modules = append(
-		opts.extensions.modules,
-		k6foundry.Module{Path: mfile.Module.Mod.Path, ReplacePath: moddir},
+	if err := resolveK6Repo(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	exe := filepath.Base(defaultK6Output())
+
+	var (
+		store *cache.Cache
+		key   string
 	)
 
-	for _, rep := range mfile.Replace {
-		opts.replacements.modules = append(
-			opts.replacements.modules,
-			k6foundry.Module{Path: rep.Old.Path, ReplacePath: rep.New.Path},
-		)
-	}
+	if !opts.noCache {
+		store, key = lookupBuildCache(ctx, opts)
+		if store != nil {
+			if path, found := store.Get(key, exe); found {
+				slog.Info("Using cached k6 binary", "path", path)
 
-	opts.output = filepath.Join(dir, filepath.Base(defaultK6Output()))
+				opts.output = path
 
-	_, err = buildK6(ctx, opts)
+				return func() {}, nil
+			}
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "xk6-build-*") //nolint:forbidigo
 	if err != nil {
 		return nil, err
 	}
 
-	return func() {
+	cleanup := func() {
 		_ = os.RemoveAll(dir) //nolint:forbidigo
-	}, nil
+	}
+
+	opts.output = filepath.Join(dir, exe)
+
+	_, err = buildK6Binary(ctx, opts)
+	if err != nil {
+		cleanup()
+
+		return nil, err
+	}
+
+	if store == nil {
+		return cleanup, nil
+	}
+
+	path, err := store.Put(key, exe, opts.output, buildCacheMetadata(opts))
+	if err != nil {
+		slog.Warn("Failed to cache k6 binary", "error", err)
+
+		return cleanup, nil
+	}
+
+	slog.Debug("Cached k6 binary", "path", path)
+
+	cleanup()
+
+	opts.output = path
+
+	return func() {}, nil
+}
+
+// lookupBuildCache opens the binary cache and computes the cache key of the build.
+// Caching is silently skipped (nil cache is returned) if the key cannot be computed,
+// for example because floating versions cannot be resolved without network access.
+func lookupBuildCache(ctx context.Context, opts *buildOptions) (*cache.Cache, string) {
+	store, err := cache.Open("")
+	if err != nil {
+		slog.Debug("Build cache is not available", "error", err)
+
+		return nil, ""
+	}
+
+	key, err := buildCacheKeyFor(ctx, opts)
+	if err != nil {
+		slog.Debug("Build cache key cannot be computed, not caching", "error", err)
+
+		return nil, ""
+	}
+
+	slog.Debug("Build cache key", "key", key)
+
+	return store, key
+}
+
+// modfileReplacement returns the replacement of a replace directive of the go.mod file in moddir.
+// Like for the go command, relative directory paths are relative to the directory of go.mod.
+func modfileReplacement(rep *modfile.Replace, moddir string) k6foundry.Module {
+	mod := k6foundry.Module{
+		Path:           rep.Old.Path,
+		Version:        rep.Old.Version,
+		ReplacePath:    rep.New.Path,
+		ReplaceVersion: rep.New.Version,
+	}
+
+	if modfile.IsDirectoryPath(rep.New.Path) && !filepath.IsAbs(rep.New.Path) {
+		mod.ReplacePath = filepath.Join(moddir, rep.New.Path)
+	}
+
+	return mod
 }
 
 func getModfile() (*modfile.File, string, error) {
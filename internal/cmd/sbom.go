@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/grafana/k6foundry"
+	"go.k6.io/xk6/internal/sbom"
+)
+
+// writeSBOMs writes a software bill of materials next to every built binary.
+func writeSBOMs(ctx context.Context, opts *buildOptions, infos []*k6foundry.BuildInfo, outputs []string) error {
+	if len(opts.sbom) == 0 {
+		return nil
+	}
+
+	format, err := sbom.ParseFormat(opts.sbom)
+	if err != nil {
+		return err
+	}
+
+	extensions := make([]string, 0, len(opts.extensions.modules))
+	for _, mod := range opts.extensions.modules {
+		extensions = append(extensions, mod.Path)
+	}
+
+	for idx, output := range outputs {
+		bom, err := sbom.Read(output, infos[idx].K6ModPath, extensions)
+		if err != nil {
+			return err
+		}
+
+		if err := bom.DetectLicenses(ctx); err != nil {
+			slog.Warn("Failed to detect licenses", "error", err)
+		}
+
+		filename := output + format.Ext()
+
+		if err := writeSBOM(filename, bom, format); err != nil {
+			return err
+		}
+
+		slog.Info("SBOM written", "format", format, "sbom", filename)
+	}
+
+	return nil
+}
+
+func writeSBOM(filename string, bom *sbom.BOM, format sbom.Format) error {
+	file, err := os.Create(filename) //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	if err := bom.Write(file, format, getVersion()); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
@@ -3,30 +3,49 @@ package cmd
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
 	"github.com/spf13/cobra"
+	"github.com/szkiba/efa"
 	"go.k6.io/xk6/internal/sync"
+	"golang.org/x/mod/module"
 )
 
 //go:embed help/sync.md
 var syncHelp string
 
 type syncOptions struct {
-	k6version string
-	dryRun    bool
-	out       string
-	compact   bool
-	json      bool
-	markdown  bool
-	quiet     bool
+	k6version  string
+	k6repo     string
+	dryRun     bool
+	out        string
+	compact    bool
+	json       bool
+	markdown   bool
+	quiet      bool
+	check      bool
+	github     bool
+	edit       bool
+	prerelease prereleasePolicy
 }
 
+const exitCodeSyncDrift = 2
+
+var (
+	errSyncDrift    = errors.New("dependencies are not in sync with k6")
+	errGitHubOutput = errors.New(
+		"the GitHub annotations and the JSON or Markdown report cannot both be written to stdout, use --out for the report")
+)
+
 func syncCmd() *cobra.Command {
 	opts := new(syncOptions)
 
@@ -40,7 +59,13 @@ func syncCmd() *cobra.Command {
 			opts.quiet = cmd.Flags().Lookup("quiet").Changed
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return syncRunE(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), opts)
+			err := syncRunE(opts.prerelease.context(cmd.Context()), cmd.OutOrStdout(), cmd.ErrOrStderr(), opts)
+			if errors.Is(err, errSyncDrift) {
+				slog.Error(errSyncDrift.Error())
+				os.Exit(exitCodeSyncDrift) //nolint:forbidigo
+			}
+
+			return err
 		},
 		DisableAutoGenTag: true,
 	}
@@ -53,8 +78,14 @@ func syncCmd() *cobra.Command {
 
 	flags.StringVarP(&opts.k6version, "k6-version", "k", "",
 		"The k6 version to use. If not specified, uses the version from go.mod")
+	flags.StringVar(&opts.k6repo, "k6-repo", defaultK6Repo,
+		"The k6 repository to sync with (e.g. a fork, optionally with a /vN suffix)")
 	flags.BoolVarP(&opts.dryRun, "dry-run", "n", false,
 		"Do not make any changes, only log them")
+	flags.BoolVar(&opts.edit, "edit", false,
+		"Edit go.mod and go.sum directly instead of running go get")
+	flags.BoolVar(&opts.check, "check", false,
+		"Do not make any changes, exit with code 2 if the dependencies are not in sync")
 	flags.StringVarP(&opts.out, "out", "o", "",
 		"Write output to file instead of stdout")
 	flags.BoolVar(&opts.json, "json", false,
@@ -63,14 +94,28 @@ func syncCmd() *cobra.Command {
 		"Compact instead of pretty-printed JSON output")
 	flags.BoolVarP(&opts.markdown, "markdown", "m", false,
 		"Generate Markdown output")
+	flags.BoolVar(&opts.github, "github", false,
+		"Print GitHub Actions annotations for the changes to stdout")
+
+	cobra.CheckErr(prereleaseFlag(flags, &opts.prerelease))
+
+	env := efa.New(flags, appname, nil)
+
+	cobra.CheckErr(env.Bind("k6-repo"))
 
 	return cmd
 }
 
 func syncRunE(ctx context.Context, stdout, stderr io.Writer, opts *syncOptions) (problem error) {
+	if err := checkSyncOutput(opts); err != nil {
+		return err
+	}
+
 	result, err := sync.Sync(ctx, ".", &sync.Options{
-		DryRun:    opts.dryRun,
+		DryRun:    opts.dryRun || opts.check,
+		Edit:      opts.edit,
 		K6Version: opts.k6version,
+		K6Repo:    opts.k6repo,
 		Stdout:    stdout,
 		Stderr:    stderr,
 	})
@@ -96,35 +141,118 @@ func syncRunE(ctx context.Context, stdout, stderr io.Writer, opts *syncOptions)
 		output = file
 	}
 
-	if opts.quiet {
-		return nil
+	if opts.github {
+		if err := githubSyncOutput(result, stdout); err != nil {
+			return err
+		}
+	}
+
+	if !opts.quiet {
+		if err := syncOutput(result, output, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.check && len(result.Changes) != 0 {
+		return errSyncDrift
+	}
+
+	return nil
+}
+
+// checkSyncOutput rejects the GitHub annotations together with a machine readable report on stdout.
+func checkSyncOutput(opts *syncOptions) error {
+	if opts.github && (opts.json || opts.markdown) && len(opts.out) == 0 && !opts.quiet {
+		return errGitHubOutput
 	}
 
+	return nil
+}
+
+func syncOutput(result *sync.Result, output io.Writer, opts *syncOptions) error {
 	if opts.json {
 		return jsonOutput(result, output, opts.compact)
 	}
 
+	applied := !opts.dryRun && !opts.check
+
 	if opts.markdown {
-		return markdownSyncOutput(result, output)
+		return markdownSyncOutput(result, applied, output)
 	}
 
-	textSyncOutput(result, output)
+	textSyncOutput(result, applied, output)
 
 	return nil
 }
 
-func textSyncOutput(result *sync.Result, output io.Writer) {
+// githubSyncOutput prints a GitHub Actions warning annotation for each change, pointing to the
+// go.mod line to change. Changes of modules not listed in go.mod are annotated on the file.
+func githubSyncOutput(result *sync.Result, output io.Writer) error {
+	for _, change := range result.Changes {
+		props := "file=go.mod"
+		if change.Line > 0 {
+			props += fmt.Sprintf(",line=%d", change.Line)
+		}
+
+		msg := fmt.Sprintf("%s %s => %s", change.Module, fromVersion(change), change.To)
+		if len(change.Reason) != 0 {
+			msg += " (" + change.Reason + ")"
+		}
+
+		if _, err := fmt.Fprintf(output, "::warning %s,title=xk6 sync::%s\n", props, escapeAnnotation(msg)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// escapeAnnotation escapes the message of a GitHub Actions workflow command.
+func escapeAnnotation(msg string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(msg)
+}
+
+// syncHeading returns the format of the first line of the report: the changes of a dry run or check
+// are not applied, the dependencies are not in sync then.
+func syncHeading(result *sync.Result, applied bool) string {
+	switch {
+	case applied:
+		return "Dependencies have been synchronized with %s.\n\n"
+	case len(result.Changes) == 0:
+		return "Dependencies are in sync with %s.\n\n"
+	default:
+		return "Dependencies are not in sync with %s.\n\n"
+	}
+}
+
+func textSyncOutput(result *sync.Result, applied bool, output io.Writer) {
 	bold := color.New(color.FgHiWhite, color.Bold).SprintfFunc()
+	plain := color.New(color.FgWhite).FprintfFunc()
 
+	plain(output, syncHeading(result, applied), bold(k6Title(result)))
+
+	textChangesOutput(result.Changes, output)
+
+	if len(result.Secondary) != 0 {
+		textChangeListOutput("Changes go mod tidy would still make", result.Secondary, output)
+	}
+}
+
+// textChangesOutput prints the list of version changes.
+func textChangesOutput(changes []*sync.Change, output io.Writer) {
+	textChangeListOutput("Changes", changes, output)
+}
+
+// textChangeListOutput prints a list of version changes with a title.
+func textChangeListOutput(title string, changes []*sync.Change, output io.Writer) {
 	downgrade := color.New(color.FgYellow).FprintfFunc()
 	upgrade := color.New(color.FgGreen).FprintfFunc()
 	plain := color.New(color.FgWhite).FprintfFunc()
+	faint := color.New(color.FgBlack).FprintfFunc()
 
-	plain(output, "Dependencies have been synchronized with k6 %s.\n\n", bold(result.K6Version))
+	plain(output, "%s\n%s\n", title, strings.Repeat("─", utf8.RuneCountInString(title)))
 
-	plain(output, "Changes\n───────\n")
-
-	for _, change := range result.Changes {
+	for _, change := range changes {
 		fprintf := downgrade
 		symbol := "▼"
 
@@ -133,20 +261,75 @@ func textSyncOutput(result *sync.Result, output io.Writer) {
 			symbol = "▲"
 		}
 
-		fprintf(output, "%s %s\n", symbol, change.Module)
-		plain(output, "  %s => %s\n", change.From, change.To)
+		fprintf(output, "%s %s", symbol, change.Module)
+
+		if change.Indirect {
+			faint(output, " (indirect)")
+		}
+
+		plain(output, "\n  %s => %s\n", fromVersion(change), toVersion(change))
+
+		if len(change.Reason) != 0 {
+			faint(output, "  %s\n", change.Reason)
+		}
 	}
 
 	plain(output, "\n")
 }
 
+// k6Title returns the k6 version used for the sync, with the module path for forks.
+func k6Title(result *sync.Result) string {
+	if isK6Fork(result.K6Module) {
+		return result.K6Module + " " + result.K6Version
+	}
+
+	return "k6 " + result.K6Version
+}
+
+func k6MarkdownTitle(result *sync.Result) string {
+	if isK6Fork(result.K6Module) {
+		return fmt.Sprintf("`%s` `%s`", result.K6Module, result.K6Version)
+	}
+
+	return fmt.Sprintf("k6 `%s`", result.K6Version)
+}
+
+func isK6Fork(modulePath string) bool {
+	base, _, _ := module.SplitPathVersion(modulePath)
+
+	return len(base) != 0 && base != defaultK6Repo
+}
+
+// fromVersion returns the replaced version, or none if the change adds a directive to go.mod.
+func fromVersion(change *sync.Change) string {
+	if len(change.From) == 0 {
+		return "none"
+	}
+
+	return change.From
+}
+
+func toVersion(change *sync.Change) string {
+	if len(change.To) == 0 {
+		return "none"
+	}
+
+	return change.To
+}
+
 func isUpgrade(change *sync.Change) bool {
-	to, err := semver.NewVersion(change.To)
+	// a directive added to go.mod (e.g. toolchain) is an upgrade
+	if len(change.From) == 0 {
+		return true
+	}
+
+	// toolchain versions are prefixed with go (e.g. go1.24.4)
+	to, err := semver.NewVersion(strings.TrimPrefix(change.To, "go"))
 	if err != nil {
 		return false
 	}
 
-	from, err := semver.NewVersion(change.From)
+	from, err := semver.NewVersion(strings.TrimPrefix(change.From, "go"))
 	if err != nil {
 		return false
 	}
@@ -154,22 +337,50 @@ func isUpgrade(change *sync.Change) bool {
 	return to.GreaterThan(from)
 }
 
-func markdownSyncOutput(result *sync.Result, output io.Writer) error {
-	_, err := fmt.Fprintf(output, "Dependencies have been synchronized with k6 `%s`.\n\n", result.K6Version)
+func markdownSyncOutput(result *sync.Result, applied bool, output io.Writer) error {
+	_, err := fmt.Fprintf(output, syncHeading(result, applied), k6MarkdownTitle(result))
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintf(output, "**Changes**\n\n")
+	if err := markdownChangesOutput("Changes", result.Changes, output); err != nil {
+		return err
+	}
+
+	if len(result.Secondary) != 0 {
+		if _, err := fmt.Fprintln(output); err != nil {
+			return err
+		}
+
+		return markdownChangesOutput("Changes go mod tidy would still make", result.Secondary, output)
+	}
+
+	return nil
+}
+
+func markdownChangesOutput(title string, changes []*sync.Change, output io.Writer) error {
+	_, err := fmt.Fprintf(output, "**%s**\n\n", title)
 	if err != nil {
 		return err
 	}
 
-	for _, change := range result.Changes {
-		_, err = fmt.Fprintf(output, "- %s\n  `%s` => `%s`\n", change.Module, change.From, change.To)
+	for _, change := range changes {
+		indirect := ""
+		if change.Indirect {
+			indirect = " (indirect)"
+		}
+
+		_, err = fmt.Fprintf(output, "- %s%s\n  `%s` => `%s`\n", change.Module, indirect, fromVersion(change), change.To)
 		if err != nil {
 			return err
 		}
+
+		if len(change.Reason) != 0 {
+			_, err = fmt.Fprintf(output, "  %s\n", change.Reason)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
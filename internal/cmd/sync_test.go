@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.k6.io/xk6/internal/sync"
+)
+
+func TestGithubSyncOutput(t *testing.T) {
+	t.Parallel()
+
+	result := &sync.Result{
+		K6Version: "v1.2.0",
+		Changes: []*sync.Change{
+			{Module: "github.com/foo/bar", From: "v1.0.0", To: "v1.1.0", Line: 7, Reason: "100% needed"},
+			{Module: "github.com/baz/qux", From: "v0.2.0", To: "v0.1.0", Indirect: true},
+		},
+	}
+
+	var buff bytes.Buffer
+
+	if err := githubSyncOutput(result, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "::warning file=go.mod,line=7,title=xk6 sync::github.com/foo/bar v1.0.0 => v1.1.0 (100%25 needed)\n" +
+		"::warning file=go.mod,title=xk6 sync::github.com/baz/qux v0.2.0 => v0.1.0\n"
+
+	if buff.String() != expected {
+		t.Errorf("unexpected annotations:\n%s", buff.String())
+	}
+}
+
+func TestCheckSyncOutput(t *testing.T) {
+	t.Parallel()
+
+	if err := checkSyncOutput(&syncOptions{github: true, json: true}); !errors.Is(err, errGitHubOutput) {
+		t.Errorf("expected errGitHubOutput, got %v", err)
+	}
+
+	for _, opts := range []*syncOptions{
+		{github: true, json: true, out: "sync-report.json"},
+		{github: true, markdown: true, quiet: true},
+		{github: true},
+		{json: true},
+	} {
+		if err := checkSyncOutput(opts); err != nil {
+			t.Errorf("unexpected error for %+v: %v", opts, err)
+		}
+	}
+}
+
+func TestMarkdownSyncOutput_NotApplied(t *testing.T) {
+	t.Parallel()
+
+	changed := &sync.Result{
+		K6Version: "v1.2.0",
+		Changes:   []*sync.Change{{Module: "github.com/foo/bar", From: "v1.0.0", To: "v1.1.0"}},
+	}
+
+	tests := []struct {
+		result   *sync.Result
+		applied  bool
+		expected string
+	}{
+		{changed, true, "Dependencies have been synchronized with "},
+		{changed, false, "Dependencies are not in sync with "},
+		{&sync.Result{K6Version: "v1.2.0"}, false, "Dependencies are in sync with "},
+	}
+
+	for _, test := range tests {
+		var buff bytes.Buffer
+
+		if err := markdownSyncOutput(test.result, test.applied, &buff); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(buff.String(), test.expected) {
+			t.Errorf("expected the report to start with %q, got:\n%s", test.expected, buff.String())
+		}
+	}
+}
@@ -61,7 +61,11 @@ func testCmd() *cobra.Command {
 
 			opts.stdout = cmd.OutOrStdout()
 
-			err := runTestE(cmd.Context(), opts, args)
+			if err := applyManifest(cmd.Flags(), opts.buildOptions); err != nil {
+				return err
+			}
+
+			err := runTestE(opts.prerelease.context(cmd.Context()), opts, args)
 			if errors.Is(err, errTestFailed) {
 				slog.Error(errTestFailed.Error())
 				os.Exit(exitCodeTestFailed) //nolint:forbidigo
@@ -77,6 +81,7 @@ func testCmd() *cobra.Command {
 	flags.SortFlags = false
 
 	cobra.CheckErr(buildCommonFlags(flags, opts.buildOptions))
+	cobra.CheckErr(onTheFlyFlags(flags, opts.buildOptions))
 
 	flags.StringVar(&opts.k6, "k6", "", "Specify the k6 binary to use instead of building one")
 	flags.StringVarP(&opts.out, "out", "o", "", "Write output to file instead of stdout")
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.k6.io/xk6/internal/sync"
+)
+
+//go:embed help/versions.md
+var versionsHelp string
+
+func versionsCmd() *cobra.Command {
+	var (
+		json    bool
+		compact bool
+		plain   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "versions [flags] [module]",
+		Short: shortHelp(versionsHelp),
+		Long:  versionsHelp,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var modulePath string
+			if len(args) != 0 {
+				modulePath = args[0]
+			}
+
+			majors, err := sync.PublishedVersions(cmd.Context(), modulePath)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case json || compact:
+				return jsonOutput(majors, cmd.OutOrStdout(), compact)
+			case plain:
+				return plainVersionsOutput(majors, cmd.OutOrStdout())
+			default:
+				return textVersionsOutput(majors, cmd.OutOrStdout())
+			}
+		},
+		DisableAutoGenTag: true,
+	}
+
+	flags := cmd.Flags()
+
+	flags.SortFlags = false
+
+	flags.BoolVar(&plain, "plain", false, "Print only the not retracted versions, one per line")
+	flags.BoolVar(&json, "json", false, "Generate JSON output")
+	flags.BoolVarP(&compact, "compact", "c", false, "Compact instead of pretty-printed JSON output")
+
+	return cmd
+}
+
+// versionDateFormat is the format of the publish times in the text output.
+const versionDateFormat = "2006-01-02"
+
+func textVersionsOutput(majors []*sync.MajorVersions, output io.Writer) error {
+	heading := color.New(color.FgHiWhite, color.Bold).FprintfFunc()
+
+	for idx, major := range majors {
+		if idx != 0 {
+			heading(output, "\n")
+		}
+
+		heading(output, "%s\n", major.Module)
+
+		table := tabwriter.NewWriter(output, 0, 0, 3, ' ', 0) //nolint:mnd
+
+		for _, version := range major.Versions {
+			published := "-"
+			if !version.Time.IsZero() {
+				published = version.Time.Format(versionDateFormat)
+			}
+
+			var marks []string
+
+			if version.Prerelease {
+				marks = append(marks, "pre-release")
+			}
+
+			if version.Retracted {
+				marks = append(marks, "retracted")
+			}
+
+			_, err := fmt.Fprintf(table, "  %s\t%s\t%s\n", version.Version, published, strings.Join(marks, ", "))
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// plainVersionsOutput prints the versions that can be used (e.g. for shell completion), newest first.
+func plainVersionsOutput(majors []*sync.MajorVersions, output io.Writer) error {
+	for _, major := range majors {
+		for _, version := range major.Versions {
+			if version.Retracted {
+				continue
+			}
+
+			if _, err := fmt.Fprintln(output, version.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"go.k6.io/xk6/internal/sync"
+)
+
+func TestPlainVersionsOutput(t *testing.T) {
+	t.Parallel()
+
+	majors := []*sync.MajorVersions{
+		{Module: "go.k6.io/k6/v2", Versions: []*sync.PublishedVersion{{Version: "v2.0.0-rc.1", Prerelease: true}}},
+		{Module: "go.k6.io/k6", Versions: []*sync.PublishedVersion{{Version: "v1.3.1", Retracted: true}, {Version: "v1.3.0"}}},
+	}
+
+	var buff bytes.Buffer
+
+	if err := plainVersionsOutput(majors, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if buff.String() != "v2.0.0-rc.1\nv1.3.0\n" {
+		t.Errorf("unexpected output:\n%s", buff.String())
+	}
+}
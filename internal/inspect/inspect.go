@@ -0,0 +1,337 @@
+// Package inspect reads the build composition of existing k6 binaries.
+package inspect
+
+import (
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	k6ModPath = "go.k6.io/k6"
+	// requirementsConcurrency is the number of go.mod files loaded concurrently.
+	requirementsConcurrency = 8
+)
+
+// ErrNotK6 is returned when the binary does not contain the k6 module.
+var ErrNotK6 = errors.New("not a k6 binary")
+
+// Requirements returns the requirements listed in the go.mod file of a module version.
+// A module without version is a local directory (the path of a directory replacement).
+type Requirements func(ctx context.Context, path, version string) ([]module.Version, error)
+
+// Module is a Go module included in a k6 binary.
+type Module struct {
+	// Path is the module path.
+	Path string `json:"path"`
+	// Version is the module version.
+	Version string `json:"version"`
+	// Replace is the replacement of the module (local directory or module path with version), if any.
+	Replace string `json:"replace,omitempty"`
+}
+
+func (m Module) String() string {
+	if len(m.Replace) != 0 {
+		return m.Path + "=" + m.Replace
+	}
+
+	return m.Path + "@" + m.Version
+}
+
+// Composition is the build composition of a k6 binary.
+type Composition struct {
+	// K6 is the k6 module.
+	K6 Module `json:"k6"`
+	// Extensions contains the k6 extension modules.
+	Extensions []Module `json:"extensions"`
+	// Replacements contains the replaced modules that are not extensions.
+	Replacements []Module `json:"replacements,omitempty"`
+	// GoVersion is the version of Go used for the build.
+	GoVersion string `json:"go_version"`
+	// OS is the target operating system.
+	OS string `json:"os"`
+	// Arch is the target architecture.
+	Arch string `json:"arch"`
+	// ARM is the target ARM version, if any.
+	ARM string `json:"arm,omitempty"`
+	// Cgo is true if cgo was enabled.
+	Cgo bool `json:"cgo"`
+	// RaceDetector is true if the race detector was enabled.
+	RaceDetector bool `json:"race_detector"`
+	// BuildFlags contains the Go build flags.
+	BuildFlags []string `json:"build_flags"`
+}
+
+// Inspect reads the build composition of a k6 binary without executing it. The build information only
+// lists the modules compiled into the binary, so the extensions are found with the go.mod files of these
+// modules, loaded with requirements: the extensions are the modules not required by k6 or by any other
+// module of the binary, which are the direct requirements of the module k6 was built with.
+// If some go.mod files can not be loaded (e.g. offline), only the modules with a k6 related name
+// (e.g. xk6-sql) are taken as extensions among the modules not known to be required.
+func Inspect(ctx context.Context, binary string, requirements Requirements) (*Composition, error) {
+	info, err := buildinfo.ReadFile(binary)
+	if err != nil {
+		return nil, err
+	}
+
+	comp, err := fromBuildInfo(ctx, info, requirements)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(binary), err)
+	}
+
+	return comp, nil
+}
+
+// dependency is a module compiled into the binary.
+type dependency struct {
+	mod Module
+	// source is the module version providing the go.mod file, without version for a local directory.
+	source   module.Version
+	replaced bool
+}
+
+func fromBuildInfo(ctx context.Context, info *debug.BuildInfo, requirements Requirements) (*Composition, error) {
+	comp := &Composition{GoVersion: info.GoVersion, Extensions: []Module{}}
+
+	deps := make([]*dependency, 0, len(info.Deps))
+
+	var k6 *dependency
+
+	for _, dep := range info.Deps {
+		d := newDependency(dep)
+
+		if IsK6Module(dep.Path) {
+			k6 = d
+			continue
+		}
+
+		deps = append(deps, d)
+	}
+
+	if k6 == nil {
+		return nil, ErrNotK6
+	}
+
+	comp.K6 = k6.mod
+
+	// k6 and its dependencies are left out, the go.mod file of k6 lists all of them
+	required := make(map[string]bool)
+
+	k6Reqs, err := requirements(ctx, k6.source.Path, k6.source.Version)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		slog.Warn("Cannot load the requirements of k6, extensions are recognized by their module path",
+			"module", k6.source.String(), "error", err)
+	}
+
+	complete := err == nil
+
+	for _, req := range k6Reqs {
+		required[req.Path] = true
+	}
+
+	candidates := make([]*dependency, 0, len(deps))
+
+	for _, dep := range deps {
+		if !required[dep.mod.Path] {
+			candidates = append(candidates, dep)
+		}
+	}
+
+	reqs, loaded := loadRequirements(ctx, candidates, requirements)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// the extensions are the modules not required by any other module
+	for idx, modReqs := range reqs {
+		for _, req := range modReqs {
+			required[req.Path] = true
+		}
+
+		complete = complete && loaded[idx]
+	}
+
+	for _, dep := range deps {
+		switch {
+		case !required[dep.mod.Path] && (complete || isExtensionPath(dep.mod.Path)):
+			comp.Extensions = append(comp.Extensions, dep.mod)
+		case dep.replaced:
+			comp.Replacements = append(comp.Replacements, dep.mod)
+		}
+	}
+
+	comp.settings(info.Settings)
+
+	return comp, nil
+}
+
+func newDependency(dep *debug.Module) *dependency {
+	d := &dependency{
+		mod:    Module{Path: dep.Path, Version: dep.Version},
+		source: module.Version{Path: dep.Path, Version: dep.Version},
+	}
+
+	replace := dep.Replace
+
+	// a module replaced with itself is only pinned to a version (e.g. by a lock file)
+	if replace != nil && replace.Path == dep.Path {
+		d.mod.Version = replace.Version
+		d.source.Version = replace.Version
+
+		return d
+	}
+
+	if replace != nil {
+		d.replaced = true
+		d.source = module.Version{Path: replace.Path, Version: replace.Version}
+
+		d.mod.Replace = replace.Path
+		if len(replace.Version) != 0 {
+			d.mod.Replace += "@" + replace.Version
+		}
+	}
+
+	return d
+}
+
+// loadRequirements loads the requirements of the dependencies concurrently, and reports whether they could
+// be loaded. The go.mod files may be unavailable offline, and the local directories of replaced modules may
+// be missing on this machine; a missing local directory has no known requirements, but counts as loaded.
+func loadRequirements(ctx context.Context, deps []*dependency, requirements Requirements) ([][]module.Version, []bool) {
+	reqs := make([][]module.Version, len(deps))
+	loaded := make([]bool, len(deps))
+
+	var wg sync.WaitGroup
+
+	limit := make(chan struct{}, requirementsConcurrency)
+
+	for idx, dep := range deps {
+		wg.Go(func() {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			var err error
+
+			reqs[idx], err = requirements(ctx, dep.source.Path, dep.source.Version)
+
+			switch {
+			case err == nil, len(dep.source.Version) == 0 && errors.Is(err, fs.ErrNotExist):
+				loaded[idx] = true
+			case ctx.Err() == nil:
+				slog.Warn("Cannot load the requirements of module", "module", dep.source.String(), "error", err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return reqs, loaded
+}
+
+// isExtensionPath returns true if the module path looks like the path of a k6 extension
+// (e.g. github.com/grafana/xk6-sql/v2 or github.com/example/k6-output-foo).
+// It is used when the go.mod files needed to find the extensions can not be loaded.
+func isExtensionPath(path string) bool {
+	prefix, _, ok := module.SplitPathVersion(path)
+	if !ok {
+		prefix = path
+	}
+
+	return strings.Contains(prefix[strings.LastIndex(prefix, "/")+1:], "k6")
+}
+
+func (c *Composition) settings(settings []debug.BuildSetting) {
+	c.BuildFlags = []string{}
+
+	for _, setting := range settings {
+		switch setting.Key {
+		case "GOOS":
+			c.OS = setting.Value
+		case "GOARCH":
+			c.Arch = setting.Value
+		case "GOARM":
+			c.ARM = setting.Value
+		case "CGO_ENABLED":
+			c.Cgo = setting.Value == "1"
+		case "-race":
+			c.RaceDetector = setting.Value == "true"
+		case "-trimpath":
+			if setting.Value == "true" {
+				c.BuildFlags = append(c.BuildFlags, "-trimpath")
+			}
+		case "-ldflags", "-gcflags", "-asmflags", "-tags":
+			c.BuildFlags = append(c.BuildFlags, setting.Key+"="+setting.Value)
+		}
+	}
+}
+
+// IsK6Module returns true if the module path is the k6 module path (of any major version).
+func IsK6Module(path string) bool {
+	prefix, _, ok := module.SplitPathVersion(path)
+
+	return ok && prefix == k6ModPath
+}
+
+// Command returns the xk6 build arguments that would reproduce the binary.
+func (c *Composition) Command() []string {
+	args := []string{"build"}
+
+	version, repo := c.K6.Version, c.K6.Path
+
+	// forks are built by replacing the k6 module
+	if len(c.K6.Replace) != 0 {
+		rpath, rversion, found := strings.Cut(c.K6.Replace, "@")
+
+		repo = rpath
+		if found {
+			version = rversion
+		}
+	}
+
+	args = append(args, version)
+
+	if repo != k6ModPath {
+		args = append(args, "--k6-repo", repo)
+	}
+
+	for _, ext := range c.Extensions {
+		args = append(args, "--with", ext.String())
+	}
+
+	for _, rep := range c.Replacements {
+		args = append(args, "--replace", rep.Path+"="+rep.Replace)
+	}
+
+	args = append(args, "--os", c.OS, "--arch", c.Arch)
+
+	if len(c.ARM) != 0 {
+		args = append(args, "--arm", c.ARM)
+	}
+
+	if c.Cgo {
+		args = append(args, "--cgo")
+	}
+
+	if c.RaceDetector {
+		args = append(args, "--race-detector")
+	}
+
+	for _, flag := range c.BuildFlags {
+		args = append(args, "--build-flags", flag)
+	}
+
+	return args
+}
@@ -0,0 +1,232 @@
+package inspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func testBuildInfo() *debug.BuildInfo {
+	return &debug.BuildInfo{
+		GoVersion: "go1.25.0",
+		Deps: []*debug.Module{
+			{Path: "github.com/brianvoe/gofakeit/v7", Version: "v7.2.1"},
+			{Path: "github.com/example/k6-output-foo", Version: "v0.1.0"},
+			{Path: "github.com/grafana/xk6-faker", Version: "v0.4.0"},
+			{Path: "github.com/grafana/xk6-sql/v2", Version: "v2.1.0"},
+			{Path: "github.com/grafana/xk6-local", Version: "v0.0.0", Replace: &debug.Module{Path: "/src/xk6-local"}},
+			{Path: "github.com/spf13/afero", Version: "v1.1.0"},
+			{Path: "github.com/spf13/pflag", Version: "v1.0.5", Replace: &debug.Module{Path: "github.com/spf13/pflag", Version: "v1.0.6"}},
+			{Path: "github.com/foo/bar", Version: "v1.0.0", Replace: &debug.Module{Path: "github.com/baz/bar", Version: "v1.0.1"}},
+			{Path: "go.k6.io/k6/v2", Version: "v2.0.0"},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "-ldflags", Value: "-s -w"},
+			{Key: "-trimpath", Value: "true"},
+			{Key: "CGO_ENABLED", Value: "0"},
+			{Key: "GOARCH", Value: "arm"},
+			{Key: "GOOS", Value: "linux"},
+			{Key: "GOARM", Value: "7"},
+		},
+	}
+}
+
+// testRequirements returns the requirements of the modules of testBuildInfo (and of a k6 fork).
+// The local directory of github.com/grafana/xk6-local is missing.
+func testRequirements(_ context.Context, path, version string) ([]module.Version, error) {
+	k6Reqs := []module.Version{
+		{Path: "github.com/spf13/afero", Version: "v1.1.0"},
+		{Path: "github.com/spf13/pflag", Version: "v1.0.5"},
+	}
+
+	reqs := map[string][]module.Version{
+		"go.k6.io/k6/v2@v2.0.0":      k6Reqs,
+		"github.com/myorg/k6@v1.2.1": k6Reqs,
+		"github.com/grafana/xk6-faker@v0.4.0": {
+			{Path: "github.com/brianvoe/gofakeit/v7", Version: "v7.2.1"},
+			{Path: "go.k6.io/k6/v2", Version: "v2.0.0"},
+		},
+		"github.com/grafana/xk6-sql/v2@v2.1.0": {
+			{Path: "github.com/foo/bar", Version: "v1.0.0"},
+		},
+		"github.com/brianvoe/gofakeit/v7@v7.2.1":  nil,
+		"github.com/example/k6-output-foo@v0.1.0": nil,
+		"github.com/baz/bar@v1.0.1":               nil,
+	}
+
+	if len(version) == 0 {
+		return nil, fs.ErrNotExist
+	}
+
+	modReqs, found := reqs[path+"@"+version]
+	if !found {
+		return nil, fmt.Errorf("unexpected module %s@%s", path, version)
+	}
+
+	return modReqs, nil
+}
+
+func TestFromBuildInfo(t *testing.T) {
+	t.Parallel()
+
+	comp, err := fromBuildInfo(t.Context(), testBuildInfo(), testRequirements)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if comp.K6 != (Module{Path: "go.k6.io/k6/v2", Version: "v2.0.0"}) {
+		t.Errorf("unexpected k6 module: %v", comp.K6)
+	}
+
+	// extensions are not recognized by their name
+	expected := []Module{
+		{Path: "github.com/example/k6-output-foo", Version: "v0.1.0"},
+		{Path: "github.com/grafana/xk6-faker", Version: "v0.4.0"},
+		{Path: "github.com/grafana/xk6-sql/v2", Version: "v2.1.0"},
+		{Path: "github.com/grafana/xk6-local", Version: "v0.0.0", Replace: "/src/xk6-local"},
+	}
+
+	if !reflect.DeepEqual(comp.Extensions, expected) {
+		t.Errorf("unexpected extensions: %v", comp.Extensions)
+	}
+
+	if len(comp.Replacements) != 1 || comp.Replacements[0].Replace != "github.com/baz/bar@v1.0.1" {
+		t.Errorf("unexpected replacements: %v", comp.Replacements)
+	}
+
+	if comp.OS != "linux" || comp.Arch != "arm" || comp.ARM != "7" || comp.Cgo {
+		t.Errorf("unexpected platform: %+v", comp)
+	}
+
+	if !reflect.DeepEqual(comp.BuildFlags, []string{"-ldflags=-s -w", "-trimpath"}) {
+		t.Errorf("unexpected build flags: %v", comp.BuildFlags)
+	}
+}
+
+func TestFromBuildInfo_Offline(t *testing.T) {
+	t.Parallel()
+
+	offline := func(context.Context, string, string) ([]module.Version, error) {
+		return nil, errors.New("offline")
+	}
+
+	comp, err := fromBuildInfo(t.Context(), testBuildInfo(), offline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// without go.mod files, extensions are recognized by their name
+	expected := []Module{
+		{Path: "github.com/example/k6-output-foo", Version: "v0.1.0"},
+		{Path: "github.com/grafana/xk6-faker", Version: "v0.4.0"},
+		{Path: "github.com/grafana/xk6-sql/v2", Version: "v2.1.0"},
+		{Path: "github.com/grafana/xk6-local", Version: "v0.0.0", Replace: "/src/xk6-local"},
+	}
+
+	if !reflect.DeepEqual(comp.Extensions, expected) {
+		t.Errorf("unexpected extensions: %v", comp.Extensions)
+	}
+
+	if len(comp.Replacements) != 1 || comp.Replacements[0].Replace != "github.com/baz/bar@v1.0.1" {
+		t.Errorf("unexpected replacements: %v", comp.Replacements)
+	}
+
+	// a module known to be required is not an extension, whatever its name
+	info := testBuildInfo()
+	info.Deps = append(info.Deps, &debug.Module{Path: "github.com/grafana/xk6-dashboard", Version: "v0.7.0"})
+
+	partial := func(ctx context.Context, path, version string) ([]module.Version, error) {
+		if path == "github.com/grafana/xk6-faker" {
+			return nil, errors.New("offline")
+		}
+
+		reqs, err := testRequirements(ctx, path, version)
+		if IsK6Module(path) {
+			reqs = append(reqs, module.Version{Path: "github.com/grafana/xk6-dashboard", Version: "v0.7.0"})
+		}
+
+		return reqs, err
+	}
+
+	comp, err = fromBuildInfo(t.Context(), info, partial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(comp.Extensions, expected) {
+		t.Errorf("unexpected extensions: %v", comp.Extensions)
+	}
+}
+
+func TestFromBuildInfo_NotK6(t *testing.T) {
+	t.Parallel()
+
+	info := testBuildInfo()
+	info.Deps = info.Deps[:len(info.Deps)-1]
+
+	if _, err := fromBuildInfo(t.Context(), info, testRequirements); !errors.Is(err, ErrNotK6) {
+		t.Errorf("expected ErrNotK6, got %v", err)
+	}
+}
+
+func TestComposition_Command(t *testing.T) {
+	t.Parallel()
+
+	comp, err := fromBuildInfo(t.Context(), testBuildInfo(), testRequirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"build", "v2.0.0", "--k6-repo", "go.k6.io/k6/v2",
+		"--with", "github.com/example/k6-output-foo@v0.1.0",
+		"--with", "github.com/grafana/xk6-faker@v0.4.0",
+		"--with", "github.com/grafana/xk6-sql/v2@v2.1.0",
+		"--with", "github.com/grafana/xk6-local=/src/xk6-local",
+		"--replace", "github.com/foo/bar=github.com/baz/bar@v1.0.1",
+		"--os", "linux", "--arch", "arm", "--arm", "7",
+		"--build-flags", "-ldflags=-s -w", "--build-flags", "-trimpath",
+	}
+
+	if got := comp.Command(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestComposition_Command_Fork(t *testing.T) {
+	t.Parallel()
+
+	info := testBuildInfo()
+	info.Deps[len(info.Deps)-1] = &debug.Module{
+		Path: "go.k6.io/k6", Version: "v1.2.0", Replace: &debug.Module{Path: "github.com/myorg/k6", Version: "v1.2.1"},
+	}
+
+	comp, err := fromBuildInfo(t.Context(), info, testRequirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := comp.Command()[1:4]; !reflect.DeepEqual(got, []string{"v1.2.1", "--k6-repo", "github.com/myorg/k6"}) {
+		t.Errorf("unexpected fork arguments: %v", got)
+	}
+}
+
+func TestInspect_NotK6(t *testing.T) {
+	t.Parallel()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Inspect(t.Context(), exe, testRequirements); !errors.Is(err, ErrNotK6) {
+		t.Errorf("expected ErrNotK6, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package modproxy contains the directory based Go module proxy used by offline builds.
+//
+// The directory has the layout of the download cache of the Go module cache (cache/download),
+// which is also the layout expected by the go command for a file:// GOPROXY.
+package modproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	dirPerm  = 0o750
+	filePerm = 0o644
+
+	versionsDir = "@v"
+	listFile    = "list"
+	latestFile  = "@latest"
+	sumdbDir    = "sumdb"
+)
+
+// ErrNotDirectory is returned when the proxy directory is not a directory.
+var ErrNotDirectory = errors.New("not a directory")
+
+// URL returns the GOPROXY value that serves the modules of the proxy directory.
+func URL(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(abs) //nolint:forbidigo
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return "", &fs.PathError{Op: "proxy", Path: dir, Err: ErrNotDirectory}
+	}
+
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // windows drive letter
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String(), nil
+}
+
+// Export copies the module files of a Go module download cache (GOMODCACHE/cache/download)
+// into the proxy directory, then refreshes the version lists of the proxy.
+// Files already in the proxy directory are kept, so several exports can be merged.
+// It returns the number of module versions in the download cache.
+func Export(download, dir string) (int, error) {
+	count := 0
+
+	err := filepath.WalkDir(download, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(download, path)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			// checksum database tiles are not part of the proxy protocol
+			if rel == sumdbDir {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !isProxyFile(entry.Name()) {
+			return nil
+		}
+
+		if strings.HasSuffix(entry.Name(), ".mod") {
+			count++
+		}
+
+		return copyFile(path, filepath.Join(dir, rel))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, Index(dir)
+}
+
+// isProxyFile reports whether the file of the download cache is served by the proxy protocol.
+// Lock files, partial downloads and the cache's own hash files are skipped, and so are the version
+// lists, as they may list versions that were not downloaded.
+func isProxyFile(name string) bool {
+	return strings.HasSuffix(name, ".info") || strings.HasSuffix(name, ".mod") || strings.HasSuffix(name, ".zip")
+}
+
+// Index writes the version list (@v/list) and the latest version (@latest) of every module of the proxy directory.
+// Only versions with a module zip are listed, so that every listed version can be built.
+func Index(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || entry.Name() != versionsDir {
+			return nil
+		}
+
+		if err := indexModule(path); err != nil {
+			return err
+		}
+
+		return filepath.SkipDir
+	})
+}
+
+func indexModule(versions string) error {
+	zips, err := filepath.Glob(filepath.Join(versions, "*.zip"))
+	if err != nil {
+		return err
+	}
+
+	list := make([]string, 0, len(zips))
+
+	for _, zip := range zips {
+		version, err := module.UnescapeVersion(strings.TrimSuffix(filepath.Base(zip), ".zip"))
+		if err != nil || !semver.IsValid(version) {
+			continue
+		}
+
+		list = append(list, version)
+	}
+
+	semver.Sort(list)
+
+	var buff strings.Builder
+
+	for _, version := range list {
+		buff.WriteString(version + "\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(versions, listFile), []byte(buff.String()), filePerm); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	latest := latestVersion(list)
+	if len(latest) == 0 {
+		return nil
+	}
+
+	return writeLatest(versions, latest)
+}
+
+// latestVersion returns the highest release, or the highest pre-release if there are no releases.
+func latestVersion(list []string) string {
+	latest := ""
+
+	for _, version := range list {
+		if len(semver.Prerelease(version)) == 0 || len(semver.Prerelease(latest)) != 0 || len(latest) == 0 {
+			latest = version
+		}
+	}
+
+	return latest
+}
+
+func writeLatest(versions, latest string) error {
+	name, err := module.EscapeVersion(latest)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(versions, name+".info")) //nolint:forbidigo
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = json.Marshal(struct{ Version string }{Version: latest})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(filepath.Dir(versions), latestFile), data, filePerm) //nolint:forbidigo
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), dirPerm); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	in, err := os.Open(src) //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerm) //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+
+		return err
+	}
+
+	slog.Debug("Prefetched module file", "file", dst)
+
+	return out.Close()
+}
@@ -0,0 +1,114 @@
+package modproxy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.k6.io/xk6/internal/modproxy"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.FromSlash(path)) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	download := t.TempDir()
+
+	writeFiles(t, download, map[string]string{
+		"github.com/!foo/xk6-bar/@v/list":                 "v1.0.0\nv1.1.0\nv1.2.0\n",
+		"github.com/!foo/xk6-bar/@v/v1.0.0.info":          `{"Version":"v1.0.0"}`,
+		"github.com/!foo/xk6-bar/@v/v1.0.0.mod":           "module github.com/Foo/xk6-bar\n",
+		"github.com/!foo/xk6-bar/@v/v1.1.0.info":          `{"Version":"v1.1.0"}`,
+		"github.com/!foo/xk6-bar/@v/v1.1.0.mod":           "module github.com/Foo/xk6-bar\n",
+		"github.com/!foo/xk6-bar/@v/v1.1.0.zip":           "zip",
+		"github.com/!foo/xk6-bar/@v/v1.1.0.ziphash":       "h1:",
+		"github.com/!foo/xk6-bar/@v/v1.1.0.lock":          "",
+		"github.com/!foo/xk6-bar/@v/v1.2.0-rc.1.info":     `{"Version":"v1.2.0-rc.1"}`,
+		"github.com/!foo/xk6-bar/@v/v1.2.0-rc.1.mod":      "module github.com/Foo/xk6-bar\n",
+		"github.com/!foo/xk6-bar/@v/v1.2.0-rc.1.zip":      "zip",
+		"sumdb/sum.golang.org/lookup/github.com/foo@v1.0": "",
+	})
+
+	dir := t.TempDir()
+
+	count, err := modproxy.Export(download, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("expected 3 module versions, got %d", count)
+	}
+
+	versions := filepath.Join(dir, "github.com", "!foo", "xk6-bar", "@v")
+
+	// v1.0.0 has no zip, so it cannot be built
+	if list := readFile(t, filepath.Join(versions, "list")); list != "v1.1.0\nv1.2.0-rc.1\n" {
+		t.Errorf("unexpected version list: %q", list)
+	}
+
+	if latest := readFile(t, filepath.Join(dir, "github.com", "!foo", "xk6-bar", "@latest")); !strings.Contains(latest, "v1.1.0") {
+		t.Errorf("expected latest release v1.1.0, got %s", latest)
+	}
+
+	for _, name := range []string{"v1.1.0.ziphash", "v1.1.0.lock"} {
+		if _, err := os.Stat(filepath.Join(versions, name)); !errors.Is(err, os.ErrNotExist) { //nolint:forbidigo
+			t.Errorf("%s should not be exported", name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "sumdb")); !errors.Is(err, os.ErrNotExist) { //nolint:forbidigo
+		t.Error("checksum database should not be exported")
+	}
+}
+
+func TestURL(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	proxy, err := modproxy.URL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(proxy, "file:///") || !strings.HasSuffix(proxy, filepath.Base(dir)) {
+		t.Errorf("unexpected proxy URL: %s", proxy)
+	}
+
+	file := filepath.Join(dir, "file")
+
+	writeFiles(t, dir, map[string]string{"file": ""})
+
+	if _, err := modproxy.URL(file); !errors.Is(err, modproxy.ErrNotDirectory) {
+		t.Errorf("expected ErrNotDirectory, got %v", err)
+	}
+}
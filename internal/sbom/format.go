@@ -0,0 +1,252 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	toolName = "xk6"
+	// goModuleHashProperty is the property of the Go module hash (the h1: hash of go.sum).
+	goModuleHashProperty = "xk6:go_module_hash"
+)
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Licenses   []cdxLicense  `json:"licenses,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxLicense struct {
+	License cdxLicenseID `json:"license"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+func cdxComponentFor(comp *Component) cdxComponent {
+	out := cdxComponent{
+		Type:       "library",
+		BOMRef:     comp.purl(),
+		Name:       comp.Path,
+		Version:    comp.Version,
+		PURL:       comp.purl(),
+		Properties: []cdxProperty{{Name: "xk6:role", Value: string(comp.Role)}},
+	}
+
+	// the go.sum hash is not a digest of a file, it has no CycloneDX hash algorithm
+	if len(comp.Hash) != 0 {
+		out.Properties = append(out.Properties, cdxProperty{Name: goModuleHashProperty, Value: comp.Hash})
+	}
+
+	if len(comp.License) != 0 {
+		out.Licenses = []cdxLicense{{License: cdxLicenseID{ID: comp.License}}}
+	}
+
+	if len(comp.Replace) != 0 {
+		out.Properties = append(out.Properties, cdxProperty{Name: "xk6:replace", Value: comp.Replace})
+	}
+
+	return out
+}
+
+func writeCycloneDX(w io.Writer, bom *BOM, tool string) error {
+	main := cdxComponent{
+		Type:    "application",
+		BOMRef:  "binary:" + bom.Name,
+		Name:    bom.Name,
+		Version: bom.K6.Version,
+		Properties: []cdxProperty{
+			{Name: "xk6:go_version", Value: bom.GoVersion},
+		},
+	}
+
+	doc := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.NewString(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: bom.Created.Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: toolName, Version: tool}}},
+			Component: main,
+		},
+	}
+
+	doc.Components = append(doc.Components, cdxComponentFor(&bom.K6))
+
+	for idx := range bom.Components {
+		doc.Components = append(doc.Components, cdxComponentFor(&bom.Components[idx]))
+	}
+
+	refs := make([]string, 0, len(doc.Components))
+	for _, comp := range doc.Components {
+		refs = append(refs, comp.BOMRef)
+	}
+
+	doc.Dependencies = []cdxDependency{{Ref: main.BOMRef, DependsOn: refs}}
+
+	return writeJSON(w, doc)
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Comment          string            `json:"comment,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+func spdxPackageFor(id string, comp *Component) spdxPackage {
+	out := spdxPackage{
+		Name:             comp.Path,
+		SPDXID:           id,
+		VersionInfo:      comp.Version,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+		Comment:          "xk6 role: " + string(comp.Role),
+		ExternalRefs: []spdxExternalRef{
+			{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: comp.purl()},
+		},
+	}
+
+	// the go.sum hash is not a digest of a file, it has no SPDX checksum algorithm
+	if len(comp.Hash) != 0 {
+		out.Comment += ", Go module hash " + comp.Hash
+	}
+
+	if len(comp.License) != 0 {
+		out.LicenseDeclared = comp.License
+	}
+
+	if len(comp.Replace) != 0 {
+		out.Comment += ", replaced by " + comp.Replace
+	}
+
+	return out
+}
+
+func writeSPDX(w io.Writer, bom *BOM, tool string) error {
+	const mainID = "SPDXRef-Binary"
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              bom.Name,
+		DocumentNamespace: "https://go.k6.io/xk6/sbom/" + bom.Name + "-" + uuid.NewString(),
+		CreationInfo: spdxCreationInfo{
+			Created:  bom.Created.Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName + "-" + tool},
+		},
+		Packages: []spdxPackage{{
+			Name:             bom.Name,
+			SPDXID:           mainID,
+			VersionInfo:      bom.K6.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			Comment:          "built with " + bom.GoVersion,
+		}},
+		Relationships: []spdxRelationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: mainID},
+		},
+	}
+
+	comps := append([]Component{bom.K6}, bom.Components...)
+
+	for idx := range comps {
+		id := fmt.Sprintf("SPDXRef-Package-%d", idx)
+
+		doc.Packages = append(doc.Packages, spdxPackageFor(id, &comps[idx]))
+		doc.Relationships = append(doc.Relationships,
+			spdxRelationship{SPDXElementID: mainID, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: id})
+	}
+
+	return writeJSON(w, doc)
+}
+
+func writeJSON(w io.Writer, doc any) error {
+	encoder := json.NewEncoder(w)
+
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,257 @@
+// Package sbom contains the generation of software bill of materials for k6 binaries.
+package sbom
+
+import (
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-enry/go-license-detector/v4/licensedb"
+	"golang.org/x/mod/module"
+)
+
+// Format is the format of the software bill of materials.
+type Format string
+
+const (
+	// CycloneDX is the CycloneDX 1.5 JSON format.
+	CycloneDX Format = "cyclonedx"
+	// SPDX is the SPDX 2.3 JSON format.
+	SPDX Format = "spdx"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for an unknown SBOM format.
+	ErrUnsupportedFormat = errors.New("unsupported SBOM format")
+	// ErrModuleNotFound is returned when the k6 module is missing from the binary.
+	ErrModuleNotFound = errors.New("module not found in binary")
+)
+
+// ParseFormat parses the name of an SBOM format.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case CycloneDX, SPDX:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+	}
+}
+
+// Ext returns the conventional filename extension of the format.
+func (f Format) Ext() string {
+	if f == SPDX {
+		return ".spdx.json"
+	}
+
+	return ".cdx.json"
+}
+
+// Role is the role of a component in a k6 binary.
+type Role string
+
+const (
+	// RoleK6 is the k6 module.
+	RoleK6 Role = "k6"
+	// RoleExtension is a k6 extension module.
+	RoleExtension Role = "extension"
+	// RoleDependency is a transitive dependency.
+	RoleDependency Role = "dependency"
+)
+
+// Component is a Go module included in a k6 binary.
+type Component struct {
+	// Path is the module path.
+	Path string
+	// Version is the module version.
+	Version string
+	// Replace is the replacement of the module (local directory or module path), if any.
+	Replace string
+	// Hash is the go.sum hash (h1:...) of the module, if known.
+	Hash string
+	// License is the SPDX identifier of the detected license, if any.
+	License string
+	// Role is the role of the module in the binary.
+	Role Role
+}
+
+// BOM is the software bill of materials of a k6 binary.
+type BOM struct {
+	// Name is the name of the binary.
+	Name string
+	// GoVersion is the version of Go used for the build.
+	GoVersion string
+	// K6 is the k6 module.
+	K6 Component
+	// Components contains the extensions and the transitive dependencies.
+	Components []Component
+	// Created is the creation time of the bill of materials.
+	Created time.Time
+}
+
+// Read reads the module information embedded in a k6 binary.
+// The k6 module is identified by its module path, extensions by the given module paths.
+func Read(binary string, k6ModPath string, extensions []string) (*BOM, error) {
+	info, err := buildinfo.ReadFile(binary)
+	if err != nil {
+		return nil, err
+	}
+
+	bom := &BOM{
+		Name:      filepath.Base(binary),
+		GoVersion: info.GoVersion,
+		Created:   time.Now().UTC(),
+	}
+
+	found := false
+
+	for _, dep := range info.Deps {
+		comp := Component{Path: dep.Path, Version: dep.Version, Hash: dep.Sum, Role: RoleDependency}
+
+		if dep.Replace != nil {
+			comp.Replace = dep.Replace.Path
+			comp.Hash = dep.Replace.Sum
+
+			if len(dep.Replace.Version) != 0 {
+				comp.Replace += "@" + dep.Replace.Version
+			}
+		}
+
+		switch {
+		case dep.Path == k6ModPath:
+			comp.Role = RoleK6
+			bom.K6 = comp
+			found = true
+
+			continue
+		case slices.Contains(extensions, dep.Path):
+			comp.Role = RoleExtension
+		}
+
+		bom.Components = append(bom.Components, comp)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrModuleNotFound, k6ModPath)
+	}
+
+	sort.SliceStable(bom.Components, func(i, j int) bool {
+		return bom.Components[i].Role == RoleExtension && bom.Components[j].Role != RoleExtension
+	})
+
+	return bom, nil
+}
+
+// DetectLicenses detects the license of every component from its source in the Go module cache.
+// Components not available in the module cache are left without license.
+func (b *BOM) DetectLicenses(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, "go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		return err
+	}
+
+	modcache := strings.TrimSpace(string(out))
+
+	comps := make([]*Component, 0, len(b.Components)+1)
+	comps = append(comps, &b.K6)
+
+	for idx := range b.Components {
+		comps = append(comps, &b.Components[idx])
+	}
+
+	dirs := make([]string, 0, len(comps))
+	byDir := make(map[string][]*Component, len(comps))
+
+	for _, comp := range comps {
+		dir, ok := moduleDir(modcache, comp)
+		if !ok {
+			continue
+		}
+
+		// licensedb treats anything that is not an existing directory as a remote repository
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() { //nolint:forbidigo
+			continue
+		}
+
+		if _, found := byDir[dir]; !found {
+			dirs = append(dirs, dir)
+		}
+
+		byDir[dir] = append(byDir[dir], comp)
+	}
+
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	const minConfidence = 0.9
+
+	for _, result := range licensedb.Analyse(dirs...) {
+		best := licensedb.Match{}
+
+		for _, match := range result.Matches {
+			if match.Confidence >= minConfidence && match.Confidence > best.Confidence {
+				best = match
+			}
+		}
+
+		for _, comp := range byDir[result.Arg] {
+			comp.License = best.License
+		}
+	}
+
+	return nil
+}
+
+// moduleDir returns the source directory of the component.
+func moduleDir(modcache string, comp *Component) (string, bool) {
+	path, version := comp.Path, comp.Version
+
+	if len(comp.Replace) != 0 {
+		rpath, rversion, found := strings.Cut(comp.Replace, "@")
+		if !found {
+			// local directory replacement, only absolute paths can be resolved
+			return rpath, filepath.IsAbs(rpath)
+		}
+
+		path, version = rpath, rversion
+	}
+
+	epath, err := module.EscapePath(path)
+	if err != nil {
+		return "", false
+	}
+
+	eversion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Join(modcache, epath+"@"+eversion), true
+}
+
+// Write writes the bill of materials in the given format.
+// The tool parameter is the version of xk6 generating the bill of materials.
+func (b *BOM) Write(w io.Writer, format Format, tool string) error {
+	switch format {
+	case CycloneDX:
+		return writeCycloneDX(w, b, tool)
+	case SPDX:
+		return writeSPDX(w, b, tool)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// purl returns the package URL of the component.
+func (c *Component) purl() string {
+	return "pkg:golang/" + c.Path + "@" + c.Version
+}
@@ -0,0 +1,153 @@
+package sbom_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go.k6.io/xk6/internal/sbom"
+)
+
+func testBOM() *sbom.BOM {
+	return &sbom.BOM{
+		Name:      "k6",
+		GoVersion: "go1.25.0",
+		K6:        sbom.Component{Path: "go.k6.io/k6", Version: "v1.2.0", Role: sbom.RoleK6, License: "AGPL-3.0"},
+		Components: []sbom.Component{
+			{
+				Path:    "github.com/grafana/xk6-faker",
+				Version: "v0.4.0",
+				Hash:    "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+				License: "AGPL-3.0",
+				Role:    sbom.RoleExtension,
+			},
+			{Path: "github.com/spf13/afero", Version: "v1.1.0", Role: sbom.RoleDependency},
+		},
+		Created: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]sbom.Format{"cyclonedx": sbom.CycloneDX, "SPDX": sbom.SPDX} {
+		got, err := sbom.ParseFormat(name)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v", name, got, err)
+		}
+	}
+
+	if _, err := sbom.ParseFormat("syft"); !errors.Is(err, sbom.ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestBOM_Write_CycloneDX(t *testing.T) {
+	t.Parallel()
+
+	var buff bytes.Buffer
+
+	if err := testBOM().Write(&buff, sbom.CycloneDX, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Name       string `json:"name"`
+			Properties []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"properties"`
+			Licenses []struct {
+				License struct {
+					ID string `json:"id"`
+				} `json:"license"`
+			} `json:"licenses"`
+		} `json:"components"`
+	}
+
+	if err := json.Unmarshal(buff.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.BOMFormat != "CycloneDX" || len(doc.Components) != 3 {
+		t.Fatalf("unexpected document: %s", buff.String())
+	}
+
+	ext := doc.Components[1]
+	if ext.Name != "github.com/grafana/xk6-faker" || len(ext.Properties) != 2 || len(ext.Licenses) != 1 {
+		t.Fatalf("unexpected extension component: %+v", ext)
+	}
+
+	// the go.sum hash is a property, not a SHA-256 digest
+	const hash = "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	if prop := ext.Properties[1]; prop.Name != "xk6:go_module_hash" || prop.Value != hash {
+		t.Errorf("unexpected hash property: %+v", prop)
+	}
+}
+
+func TestBOM_Write_SPDX(t *testing.T) {
+	t.Parallel()
+
+	var buff bytes.Buffer
+
+	if err := testBOM().Write(&buff, sbom.SPDX, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Name            string `json:"name"`
+			LicenseDeclared string `json:"licenseDeclared"`
+		} `json:"packages"`
+		Relationships []struct {
+			RelationshipType string `json:"relationshipType"`
+		} `json:"relationships"`
+	}
+
+	if err := json.Unmarshal(buff.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// the binary itself, k6 and the two components
+	if doc.SPDXVersion != "SPDX-2.3" || len(doc.Packages) != 4 || len(doc.Relationships) != 4 {
+		t.Fatalf("unexpected document: %s", buff.String())
+	}
+
+	if doc.Packages[1].Name != "go.k6.io/k6" || doc.Packages[1].LicenseDeclared != "AGPL-3.0" {
+		t.Errorf("unexpected k6 package: %+v", doc.Packages[1])
+	}
+
+	if doc.Packages[3].LicenseDeclared != "NOASSERTION" {
+		t.Errorf("expected NOASSERTION, got %s", doc.Packages[3].LicenseDeclared)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test binary has no k6 in it, but any of its dependencies can stand in
+	bom, err := sbom.Read(exe, "github.com/go-enry/go-license-detector/v4", []string{"github.com/spf13/cobra"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bom.K6.Role != sbom.RoleK6 || len(bom.Components) == 0 {
+		t.Fatalf("unexpected BOM: %+v", bom)
+	}
+
+	if _, err := sbom.Read(exe, "go.k6.io/k6", nil); !errors.Is(err, sbom.ErrModuleNotFound) {
+		t.Errorf("expected ErrModuleNotFound, got %v", err)
+	}
+}
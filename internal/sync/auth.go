@@ -0,0 +1,364 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	gosync "sync"
+)
+
+const (
+	// envProxyTokens contains bearer tokens for Go proxy hosts, as a comma separated list of host=token pairs.
+	envProxyTokens = "XK6_PROXY_TOKENS"
+
+	defaultGoAuth = "netrc"
+	goAuthOff     = "off"
+	goAuthNetrc   = "netrc"
+	goAuthGit     = "git"
+)
+
+// credentialSet is a set of HTTP headers returned by a GOAUTH command for URL prefixes.
+type credentialSet struct {
+	prefixes []string
+	header   http.Header
+}
+
+var (
+	goAuthMu    gosync.Mutex                       //nolint:gochecknoglobals
+	goAuthCache = make(map[string][]credentialSet) //nolint:gochecknoglobals
+)
+
+// addCredentials adds the credentials of the Go proxy to the request, like the go command does.
+// A bearer token from XK6_PROXY_TOKENS takes precedence, then the GOAUTH authentication
+// commands (by default netrc) are tried in order until one provides credentials.
+// Credentials are only sent over HTTPS.
+func addCredentials(ctx context.Context, req *http.Request) {
+	if req.URL.Scheme != "https" {
+		return
+	}
+
+	if token, found := proxyToken(req.URL); found {
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return
+	}
+
+	for _, entry := range goAuthEntries() {
+		switch fields := strings.Fields(entry); {
+		case entry == goAuthOff:
+			return
+		case entry == goAuthNetrc:
+			if login, password, found := netrcCredentials(req.URL.Hostname()); found {
+				req.SetBasicAuth(login, password)
+
+				return
+			}
+		case fields[0] == goAuthGit:
+			if login, password, found := gitCredentials(ctx, strings.TrimSpace(strings.TrimPrefix(entry, goAuthGit)), req.URL); found {
+				req.SetBasicAuth(login, password)
+
+				return
+			}
+		default:
+			if header := commandCredentials(ctx, fields, req.URL, nil); header != nil {
+				setHeader(req, header)
+
+				return
+			}
+		}
+	}
+}
+
+// retryCredentials is called after a 4xx response. Following the GOAUTH protocol, the authentication
+// commands are invoked again with the URL as argument and the response on stdin. It reports whether
+// new credentials were added to the request, in which case the request should be sent once more.
+func retryCredentials(ctx context.Context, req *http.Request, resp *http.Response) bool {
+	if req.URL.Scheme != "https" {
+		return false
+	}
+
+	dump, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range goAuthEntries() {
+		fields := strings.Fields(entry)
+
+		if entry == goAuthOff {
+			return false
+		}
+
+		if entry == goAuthNetrc || fields[0] == goAuthGit {
+			continue
+		}
+
+		if header := commandCredentials(ctx, fields, req.URL, dump); header != nil {
+			setHeader(req, header)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func goAuthEntries() []string {
+	value := goEnv("GOAUTH")
+	if len(value) == 0 {
+		value = defaultGoAuth
+	}
+
+	var entries []string
+
+	for entry := range strings.SplitSeq(value, ";") {
+		if entry = strings.TrimSpace(entry); len(entry) != 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// proxyToken returns the bearer token of the proxy host (with or without port) from XK6_PROXY_TOKENS.
+func proxyToken(u *url.URL) (string, bool) {
+	for pair := range strings.SplitSeq(os.Getenv(envProxyTokens), ",") { //nolint:forbidigo
+		host, token, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if found && (host == u.Host || host == u.Hostname()) {
+			return token, true
+		}
+	}
+
+	return "", false
+}
+
+func setHeader(req *http.Request, header http.Header) {
+	for key, values := range header {
+		req.Header.Del(key)
+
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+// netrcCredentials returns the login and password of the host from the NETRC file
+// (by default .netrc, or _netrc on windows, in the home directory).
+func netrcCredentials(host string) (string, string, bool) {
+	file := os.Getenv("NETRC") //nolint:forbidigo
+	if len(file) == 0 {
+		home, err := os.UserHomeDir() //nolint:forbidigo
+		if err != nil {
+			return "", "", false
+		}
+
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+
+		file = filepath.Join(home, name)
+	}
+
+	data, err := os.ReadFile(file) //nolint:forbidigo,gosec
+	if err != nil {
+		return "", "", false
+	}
+
+	return parseNetrc(string(data), host)
+}
+
+// parseNetrc returns the login and password of the machine from the content of a netrc file.
+// The default entry is used if there is no entry for the machine.
+func parseNetrc(data, host string) (string, string, bool) {
+	type entry struct {
+		login, password string
+	}
+
+	var (
+		current *entry
+		matched *entry
+		deflt   *entry
+	)
+
+	tokens := strings.Fields(data)
+
+	for idx := 0; idx < len(tokens); idx++ {
+		switch tokens[idx] {
+		case "machine":
+			current = new(entry)
+
+			if idx+1 < len(tokens) && tokens[idx+1] == host && matched == nil {
+				matched = current
+			}
+
+			idx++
+		case "default":
+			current = new(entry)
+			deflt = current
+		case "login", "password":
+			if current == nil || idx+1 >= len(tokens) {
+				continue
+			}
+
+			if tokens[idx] == "login" {
+				current.login = tokens[idx+1]
+			} else {
+				current.password = tokens[idx+1]
+			}
+
+			idx++
+		case "macdef":
+			// macro definitions end at an empty line, they never contain credentials
+			current = nil
+		}
+	}
+
+	if matched == nil {
+		matched = deflt
+	}
+
+	if matched == nil {
+		return "", "", false
+	}
+
+	return matched.login, matched.password, true
+}
+
+// gitCredentials returns the credentials of the URL from the git credential helpers configured in dir.
+func gitCredentials(ctx context.Context, dir string, u *url.URL) (string, string, bool) {
+	input := "protocol=" + u.Scheme + "\nhost=" + u.Host + "\npath=" + strings.TrimPrefix(u.Path, "/") + "\n\n"
+
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0") //nolint:forbidigo
+
+	out, err := cmd.Output()
+	if err != nil {
+		slog.Debug("git credential fill failed", "host", u.Host, "error", err)
+
+		return "", "", false
+	}
+
+	var login, password string
+
+	for line := range strings.Lines(string(out)) {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+
+		switch key {
+		case "username":
+			login = value
+		case "password":
+			password = value
+		}
+	}
+
+	return login, password, len(password) != 0
+}
+
+// commandCredentials returns the headers provided by a GOAUTH command for the URL.
+// Without response, the command is invoked without arguments and its output is cached,
+// otherwise it is invoked with the URL as argument and the response on stdin.
+func commandCredentials(ctx context.Context, command []string, u *url.URL, response []byte) http.Header {
+	key := strings.Join(command, " ")
+
+	goAuthMu.Lock()
+	defer goAuthMu.Unlock()
+
+	sets, found := goAuthCache[key]
+
+	if !found || response != nil {
+		args := command[1:]
+		if response != nil {
+			args = append(args[:len(args):len(args)], u.String())
+		}
+
+		cmd := exec.CommandContext(ctx, command[0], args...) //nolint:gosec
+		cmd.Stdin = bytes.NewReader(response)
+
+		out, err := cmd.Output()
+		if err != nil {
+			slog.Debug("GOAUTH command failed", "command", key, "error", err)
+
+			return nil
+		}
+
+		sets = parseCredentialSets(out)
+
+		goAuthCache[key] = append(sets, goAuthCache[key]...)
+	}
+
+	return matchCredentials(sets, u.String())
+}
+
+// parseCredentialSets parses the output of a GOAUTH command: URL lines followed by a blank line,
+// then header lines followed by a blank line, repeated.
+func parseCredentialSets(data []byte) []credentialSet {
+	var (
+		sets    []credentialSet
+		current credentialSet
+		headers bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case len(line) == 0 && !headers:
+			headers = len(current.prefixes) != 0
+		case len(line) == 0:
+			sets = append(sets, current)
+			current, headers = credentialSet{}, false
+		case !headers:
+			current.prefixes = append(current.prefixes, line)
+		default:
+			key, value, found := strings.Cut(line, ":")
+			if !found {
+				continue
+			}
+
+			if current.header == nil {
+				current.header = make(http.Header)
+			}
+
+			current.header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+
+	if headers {
+		sets = append(sets, current)
+	}
+
+	return sets
+}
+
+// matchCredentials returns the headers of the credential set with the longest URL prefix matching the URL.
+func matchCredentials(sets []credentialSet, rawURL string) http.Header {
+	var (
+		best   http.Header
+		length int
+	)
+
+	for _, set := range sets {
+		for _, prefix := range set.prefixes {
+			if strings.HasPrefix(rawURL, prefix) && len(prefix) > length {
+				best, length = set.header, len(prefix)
+			}
+		}
+	}
+
+	return best
+}
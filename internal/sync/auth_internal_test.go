@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	t.Parallel()
+
+	const netrc = `
+machine proxy.example.com login alice password secret
+machine other.example.com
+  login bob
+  password hunter2
+default login anonymous password guest
+`
+
+	tests := map[string][2]string{
+		"proxy.example.com":   {"alice", "secret"},
+		"other.example.com":   {"bob", "hunter2"},
+		"unknown.example.com": {"anonymous", "guest"},
+	}
+
+	for host, expected := range tests {
+		login, password, found := parseNetrc(netrc, host)
+		if !found || login != expected[0] || password != expected[1] {
+			t.Errorf("%s: expected %v, got %s/%s (%v)", host, expected, login, password, found)
+		}
+	}
+
+	if _, _, found := parseNetrc("machine proxy.example.com login alice password secret", "other"); found {
+		t.Error("expected no credentials without default entry")
+	}
+}
+
+func TestParseCredentialSets(t *testing.T) {
+	t.Parallel()
+
+	output := "https://example.com\nhttps://example.net/api/\n\nAuthorization: Basic abc\n\n" +
+		"https://example.net/api/private/\n\nAuthorization: Bearer xyz\nX-Extra: 1\n\n"
+
+	sets := parseCredentialSets([]byte(output))
+	if len(sets) != 2 {
+		t.Fatalf("expected 2 credential sets, got %d", len(sets))
+	}
+
+	if got := matchCredentials(sets, "https://example.net/api/private/mod/@latest").Get("Authorization"); got != "Bearer xyz" {
+		t.Errorf("expected the longest prefix to win, got %q", got)
+	}
+
+	if got := matchCredentials(sets, "https://example.com/mod/@latest").Get("Authorization"); got != "Basic abc" {
+		t.Errorf("unexpected credentials: %q", got)
+	}
+
+	if matchCredentials(sets, "https://unknown.com/") != nil {
+		t.Error("expected no credentials for unknown URL")
+	}
+}
+
+// newAuthProxy returns an HTTPS proxy that requires the given Authorization header.
+func newAuthProxy(t *testing.T, authorization string) {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != authorization {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"version":"v1.2.3"}`)
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("GOENV", filepath.Join(t.TempDir(), "env"))
+	t.Setenv(envProxyTokens, "")
+
+	useServerClient(t, srv)
+}
+
+// useServerClient sends the Go proxy requests of the test with the client of the server,
+// which trusts the certificate of a TLS server.
+func useServerClient(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+
+	client := http.DefaultClient
+	http.DefaultClient = srv.Client()
+
+	t.Cleanup(func() { http.DefaultClient = client })
+}
+
+func TestGoProxyGet_Netrc(t *testing.T) {
+	newAuthProxy(t, "Basic YWxpY2U6c2VjcmV0") // alice:secret
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password secret\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	t.Setenv("NETRC", netrc)
+	t.Setenv("GOAUTH", "")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Setenv("GOAUTH", "off")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+}
+
+func TestGoProxyGet_Token(t *testing.T) {
+	newAuthProxy(t, "Bearer s3cr3t")
+
+	t.Setenv(envProxyTokens, "other.example.com=wrong, 127.0.0.1=s3cr3t")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGoProxyGet_GoAuthCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the GOAUTH command is a shell script")
+	}
+
+	newAuthProxy(t, "Bearer from-command")
+
+	script := filepath.Join(t.TempDir(), "goauth.sh")
+	content := "#!/bin/sh\nprintf '" + os.Getenv("GOPROXY") + "/\\n\\nAuthorization: Bearer from-command\\n\\n'\n" //nolint:forbidigo
+
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOAUTH", script)
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGoProxyGet_PlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) != 0 {
+			t.Errorf("unexpected credentials over HTTP: %q", r.Header.Get("Authorization"))
+		}
+
+		_, _ = fmt.Fprint(w, `{"version":"v1.2.3"}`)
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("GOENV", filepath.Join(t.TempDir(), "env"))
+	t.Setenv(envProxyTokens, "127.0.0.1=s3cr3t")
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password secret\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	t.Setenv("NETRC", netrc)
+	t.Setenv("GOAUTH", "")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Setenv(envProxyTokens, "")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-bar"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
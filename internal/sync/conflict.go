@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"errors"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrK6Conflict is returned when the k6 requirements of the extensions cannot be satisfied by a single k6 module.
+var ErrK6Conflict = errors.New("extensions require different k6 major versions")
+
+// K6Requirement is the k6 module required by an extension.
+type K6Requirement struct {
+	// Extension is the module path of the extension, or the origin of the requirement (e.g. --k6-version).
+	Extension string `json:"extension"`
+	// Module is the required k6 module path (e.g. go.k6.io/k6/v2).
+	Module string `json:"module"`
+	// Version is the required k6 version.
+	Version string `json:"version"`
+}
+
+// K6ConflictError describes conflicting k6 requirements.
+type K6ConflictError struct {
+	// Requirements contains every k6 requirement taken into account.
+	Requirements []K6Requirement
+}
+
+// Error returns the message of the error, including a table of the requirements.
+func (e *K6ConflictError) Error() string {
+	var buff strings.Builder
+
+	buff.WriteString(ErrK6Conflict.Error())
+	buff.WriteString(":\n\n")
+
+	table := tabwriter.NewWriter(&buff, 0, 0, 3, ' ', 0)
+
+	_, _ = table.Write([]byte("  EXTENSION\tK6 MODULE\tVERSION\n"))
+
+	for _, req := range e.Requirements {
+		_, _ = table.Write([]byte("  " + req.Extension + "\t" + req.Module + "\t" + req.Version + "\n"))
+	}
+
+	_ = table.Flush()
+
+	return strings.TrimRight(buff.String(), "\n")
+}
+
+// Unwrap returns ErrK6Conflict.
+func (e *K6ConflictError) Unwrap() error {
+	return ErrK6Conflict
+}
+
+// CheckK6Requirements checks that the k6 module path (and therefore the k6 major version) required
+// by every extension is the given k6 module path. The pinned k6 is listed as the requirement of the origin.
+func CheckK6Requirements(origin, k6Path, k6Version string, reqs []K6Requirement) error {
+	all := append([]K6Requirement{{Extension: origin, Module: k6Path, Version: k6Version}}, reqs...)
+
+	return checkK6Majors(all)
+}
+
+// checkK6Majors returns a *K6ConflictError if the requirements refer to different k6 module paths.
+func checkK6Majors(reqs []K6Requirement) error {
+	for _, req := range reqs[1:] {
+		if req.Module != reqs[0].Module {
+			return &K6ConflictError{Requirements: reqs}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func localExtension(t *testing.T, path, k6require string) ExtensionModule {
+	t.Helper()
+
+	dir := t.TempDir()
+	content := "module " + path + "\n\nrequire " + k6require + "\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(content), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	return ExtensionModule{Path: path, LocalPath: dir}
+}
+
+func TestResolveK6ModuleForExtensions_Highest(t *testing.T) {
+	t.Parallel()
+
+	exts := []ExtensionModule{
+		localExtension(t, "github.com/grafana/xk6-foo", "go.k6.io/k6 v1.2.0"),
+		localExtension(t, "github.com/grafana/xk6-bar", "go.k6.io/k6 v1.4.1"),
+		localExtension(t, "github.com/grafana/xk6-baz", "go.k6.io/k6 v1.3.0"),
+	}
+
+	path, version, err := ResolveK6ModuleForExtensions(t.Context(), exts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "go.k6.io/k6" || version != "v1.4.1" {
+		t.Errorf("expected go.k6.io/k6@v1.4.1, got %s@%s", path, version)
+	}
+}
+
+func TestResolveK6ModuleForExtensions_Conflict(t *testing.T) {
+	t.Parallel()
+
+	exts := []ExtensionModule{
+		localExtension(t, "github.com/grafana/xk6-foo", "go.k6.io/k6 v1.2.0"),
+		localExtension(t, "github.com/grafana/xk6-bar", "go.k6.io/k6/v2 v2.0.0"),
+	}
+
+	_, _, err := ResolveK6ModuleForExtensions(t.Context(), exts)
+	if !errors.Is(err, ErrK6Conflict) {
+		t.Fatalf("expected ErrK6Conflict, got %v", err)
+	}
+
+	var conflict *K6ConflictError
+	if !errors.As(err, &conflict) || len(conflict.Requirements) != 2 {
+		t.Fatalf("expected conflict with 2 requirements, got %v", err)
+	}
+
+	for _, expected := range []string{"github.com/grafana/xk6-bar", "go.k6.io/k6/v2", "v2.0.0"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in error message:\n%s", expected, err)
+		}
+	}
+}
+
+func TestCheckK6Requirements(t *testing.T) {
+	t.Parallel()
+
+	reqs := []K6Requirement{
+		{Extension: "github.com/grafana/xk6-foo", Module: "go.k6.io/k6", Version: "v1.2.0"},
+	}
+
+	if err := CheckK6Requirements("--k6-version", "go.k6.io/k6", "v1.5.0", reqs); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := CheckK6Requirements("--k6-version", "go.k6.io/k6/v2", "v2.0.0", reqs)
+	if !errors.Is(err, ErrK6Conflict) {
+		t.Fatalf("expected ErrK6Conflict, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "--k6-version") {
+		t.Errorf("expected the pinned version in error message:\n%s", err)
+	}
+}
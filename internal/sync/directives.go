@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"fmt"
+	"go/version"
+
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	goDirective        = "go"
+	toolchainDirective = "toolchain"
+)
+
+// directiveChanges returns the changes of the go and toolchain directives needed to align the extension
+// with k6. The directives are only raised, never lowered: the extension may need a newer Go version
+// than k6. The changes use the go and toolchain pseudo-modules, so they are applied by go get like the
+// module changes (e.g. go get go@1.24.0 toolchain@go1.24.4).
+func directiveChanges(extModfile, k6Modfile *modfile.File, k6Version string) []*Change {
+	var changes []*Change
+
+	extGo := goVersion(extModfile)
+	k6Go := goVersion(k6Modfile)
+
+	if len(k6Go) != 0 && version.Compare("go"+extGo, "go"+k6Go) < 0 {
+		changes = append(changes, &Change{
+			Module: goDirective,
+			From:   extGo,
+			To:     k6Go,
+			Reason: fmt.Sprintf("k6 %s requires go %s", k6Version, k6Go),
+		})
+
+		extGo = k6Go
+	}
+
+	if k6Modfile.Toolchain == nil {
+		return changes
+	}
+
+	k6Toolchain := k6Modfile.Toolchain.Name
+
+	// without toolchain directive, the go directive is the minimum toolchain
+	extToolchain := "go" + extGo
+	from := ""
+
+	if extModfile.Toolchain != nil {
+		extToolchain, from = extModfile.Toolchain.Name, extModfile.Toolchain.Name
+	}
+
+	if version.Compare(extToolchain, k6Toolchain) < 0 {
+		changes = append(changes, &Change{
+			Module: toolchainDirective,
+			From:   from,
+			To:     k6Toolchain,
+			Reason: fmt.Sprintf("k6 %s uses toolchain %s", k6Version, k6Toolchain),
+		})
+	}
+
+	return changes
+}
+
+func goVersion(mf *modfile.File) string {
+	if mf.Go == nil {
+		return ""
+	}
+
+	return mf.Go.Version
+}
@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+func TestDirectiveChanges(t *testing.T) {
+	t.Parallel()
+
+	parse := func(data string) *modfile.File {
+		mf, err := modfile.Parse(modFile, []byte(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return mf
+	}
+
+	k6 := parse("module go.k6.io/k6\n\ngo 1.24.0\n\ntoolchain go1.24.4\n")
+
+	tests := []struct {
+		name string
+		ext  string
+		want []string
+	}{
+		{"older go", "module ext\n\ngo 1.22\n", []string{"go 1.22 1.24.0", "toolchain  go1.24.4"}},
+		{"older toolchain", "module ext\n\ngo 1.24.0\n\ntoolchain go1.24.1\n", []string{"toolchain go1.24.1 go1.24.4"}},
+		{"newer go", "module ext\n\ngo 1.25.0\n", nil},
+		{"aligned", "module ext\n\ngo 1.24.0\n\ntoolchain go1.24.4\n", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+
+		for _, change := range directiveChanges(parse(tt.ext), k6, "v1.2.0") {
+			got = append(got, change.Module+" "+change.From+" "+change.To)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
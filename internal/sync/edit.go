@@ -0,0 +1,360 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/version"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const sumFile = "go.sum"
+
+// editModFiles applies the changes by editing go.mod and go.sum directly, without the go command.
+// Only the changed requirements and directives are modified, so the diff is minimal and predictable.
+// The go.sum hashes of the new versions are taken from the verified go.mod files, and the module zip
+// hashes from the module cache or the checksum database. The changes go mod tidy would still make are
+// returned, because unlike go get, the requirements of the new versions are not applied.
+func editModFiles(ctx context.Context, dir string, mf *modfile.File, changes []*Change, write bool) ([]*Change, error) {
+	sums, err := readSums(filepath.Join(dir, sumFile))
+	if err != nil {
+		return nil, err
+	}
+
+	// the zip hash is only added if the previous version had one, i.e. the module provides packages to the build
+	zipped := make(map[string]bool)
+
+	for line := range sums {
+		if fields := strings.Fields(line); len(fields) == 3 && !strings.HasSuffix(fields[1], "/go.mod") { //nolint:mnd
+			zipped[fields[0]+"@"+fields[1]] = true
+		}
+	}
+
+	var newMods []*modfile.File
+
+	for _, change := range changes {
+		switch change.Module {
+		case goDirective:
+			err = mf.AddGoStmt(change.To)
+		case toolchainDirective:
+			err = mf.AddToolchainStmt(change.To)
+		default:
+			var changed *modfile.File
+
+			// the zip hashes are only needed to write go.sum, a dry run must not fail without them
+			changed, err = editRequire(ctx, mf, change, sums, write && zipped[change.Module+"@"+change.From])
+
+			newMods = append(newMods, changed)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mf.Cleanup()
+
+	secondary := tidyChanges(mf, newMods)
+
+	if !write {
+		return secondary, nil
+	}
+
+	data, err := mf.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFilePreserveMode(filepath.Join(dir, modFile), data); err != nil {
+		return nil, err
+	}
+
+	if len(sums) != 0 {
+		if err := writeFilePreserveMode(filepath.Join(dir, sumFile), formatSums(sums)); err != nil {
+			return nil, err
+		}
+	}
+
+	return secondary, nil
+}
+
+// editRequire sets the required version of the changed module, and adds its go.sum lines.
+// Modules not listed in go.mod are added as indirect requirements. It returns the go.mod of the new version.
+func editRequire(
+	ctx context.Context,
+	mf *modfile.File,
+	change *Change,
+	sums map[string]struct{},
+	zipped bool,
+) (*modfile.File, error) {
+	slog.Debug("Editing requirement", "module", change.Module, "from", change.From, "to", change.To)
+
+	listed := false
+
+	for _, req := range mf.Require {
+		if req.Mod.Path == change.Module {
+			listed = true
+		}
+	}
+
+	if listed {
+		if err := mf.AddRequire(change.Module, change.To); err != nil {
+			return nil, err
+		}
+	} else {
+		mf.AddNewRequire(change.Module, change.To, true)
+	}
+
+	data, err := getModuleData(ctx, change.Module, change.To)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := goModHash(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sums[fmt.Sprintf("%s %s/go.mod %s", change.Module, change.To, hash)] = struct{}{}
+
+	if zipped {
+		line, err := zipHashLine(ctx, change.Module, change.To)
+		if err != nil {
+			return nil, err
+		}
+
+		sums[line] = struct{}{}
+	}
+
+	changed, err := modfile.ParseLax(modFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed.Module != nil {
+		changed.Module.Mod.Version = change.To
+	}
+
+	return changed, nil
+}
+
+// zipHashLine returns the go.sum line of the module zip, from the module cache if the module has been
+// downloaded, otherwise from the checksum database.
+func zipHashLine(ctx context.Context, modulePath, version string) (string, error) {
+	prefix := modulePath + " " + version + " "
+
+	if file, err := moduleCacheFile(modulePath, version, ".ziphash"); err == nil {
+		if data, err := os.ReadFile(file); err == nil { //nolint:forbidigo,gosec
+			return prefix + strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	if !sumdbEnabled(modulePath) {
+		return "", fmt.Errorf("%w: the zip hash of %s@%s is not in the module cache and the checksum "+
+			"database is disabled, run go mod download %s@%s first", errMissingZipHash, modulePath, version, modulePath, version)
+	}
+
+	lines, _, err := sumdbLookup(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return line, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s@%s", errMissingZipHash, modulePath, version)
+}
+
+var errMissingZipHash = errors.New("missing module zip hash")
+
+// moduleCacheFile returns the path of a file of the module version in the download cache of GOMODCACHE.
+func moduleCacheFile(modulePath, version, ext string) (string, error) {
+	modcache := goEnv("GOMODCACHE")
+	if len(modcache) == 0 {
+		gopath := filepath.SplitList(goEnv("GOPATH"))
+		if len(gopath) != 0 && len(gopath[0]) != 0 {
+			modcache = filepath.Join(gopath[0], "pkg", "mod")
+		} else {
+			home, err := os.UserHomeDir() //nolint:forbidigo
+			if err != nil {
+				return "", err
+			}
+
+			modcache = filepath.Join(home, "go", "pkg", "mod")
+		}
+	}
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(modcache, "cache", "download", filepath.FromSlash(escapedPath), "@v", escapedVersion+ext), nil
+}
+
+// tidyChanges returns the requirements of the new module versions that the edited go.mod does not satisfy.
+// The go command would raise these requirements (go mod tidy), as minimal version selection picks the
+// highest required version. Modules not listed in go.mod are not reported, they are only added by
+// go mod tidy if they provide packages to the build.
+func tidyChanges(mf *modfile.File, newMods []*modfile.File) []*Change {
+	required := make(map[string]*modfile.Require)
+
+	for _, req := range mf.Require {
+		required[req.Mod.Path] = req
+	}
+
+	secondary := make(map[string]*Change)
+
+	for _, newMod := range newMods {
+		if newMod == nil || newMod.Module == nil {
+			continue
+		}
+
+		requirer := newMod.Module.Mod.Path + "@" + newMod.Module.Mod.Version
+
+		// the go directive must not be lower than the go directive of the dependencies
+		if newMod.Go != nil && version.Compare("go"+goVersion(mf), "go"+newMod.Go.Version) < 0 {
+			if prev, found := secondary[goDirective]; !found || version.Compare("go"+prev.To, "go"+newMod.Go.Version) < 0 {
+				secondary[goDirective] = &Change{
+					Module: goDirective,
+					From:   goVersion(mf),
+					To:     newMod.Go.Version,
+					Reason: fmt.Sprintf("%s requires go %s", requirer, newMod.Go.Version),
+					Line:   goLine(mf),
+				}
+			}
+		}
+
+		for _, req := range newMod.Require {
+			current, listed := required[req.Mod.Path]
+			if !listed || semver.Compare(current.Mod.Version, req.Mod.Version) >= 0 {
+				continue
+			}
+
+			if prev, found := secondary[req.Mod.Path]; found && semver.Compare(prev.To, req.Mod.Version) >= 0 {
+				continue
+			}
+
+			secondary[req.Mod.Path] = &Change{
+				Module:   req.Mod.Path,
+				From:     current.Mod.Version,
+				To:       req.Mod.Version,
+				Indirect: current.Indirect,
+				Reason:   fmt.Sprintf("%s required by %s", req.Mod.Version, requirer),
+				Line:     requireLine(current),
+			}
+		}
+	}
+
+	changes := make([]*Change, 0, len(secondary))
+
+	for _, change := range secondary {
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Module < changes[j].Module })
+
+	return changes
+}
+
+func requireLine(req *modfile.Require) int {
+	if req.Syntax == nil {
+		return 0
+	}
+
+	return req.Syntax.Start.Line
+}
+
+func goLine(mf *modfile.File) int {
+	if mf.Go == nil || mf.Go.Syntax == nil {
+		return 0
+	}
+
+	return mf.Go.Syntax.Start.Line
+}
+
+func readSums(filename string) (map[string]struct{}, error) {
+	sums := make(map[string]struct{})
+
+	data, err := os.ReadFile(filepath.Clean(filename)) //nolint:forbidigo
+	if errors.Is(err, fs.ErrNotExist) {
+		return sums, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			sums[line] = struct{}{}
+		}
+	}
+
+	return sums, nil
+}
+
+// formatSums returns the content of go.sum, sorted like the go command does:
+// by module path and version, with the zip hash before the go.mod hash.
+func formatSums(sums map[string]struct{}) []byte {
+	lines := make([]string, 0, len(sums))
+
+	for line := range sums {
+		lines = append(lines, line)
+	}
+
+	key := func(line string) (string, string, bool) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 { //nolint:mnd
+			return line, "", false
+		}
+
+		version, isMod := strings.CutSuffix(fields[1], "/go.mod")
+
+		return fields[0], version, isMod
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		pathI, versionI, modI := key(lines[i])
+		pathJ, versionJ, modJ := key(lines[j])
+
+		if pathI != pathJ {
+			return pathI < pathJ
+		}
+
+		if cmp := semver.Compare(versionI, versionJ); cmp != 0 {
+			return cmp < 0
+		}
+
+		return !modI && modJ
+	})
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func writeFilePreserveMode(filename string, data []byte) error {
+	mode := os.FileMode(goModFileMode)
+
+	if info, err := os.Stat(filename); err == nil { //nolint:forbidigo
+		mode = info.Mode().Perm()
+	}
+
+	return os.WriteFile(filename, data, mode) //nolint:forbidigo
+}
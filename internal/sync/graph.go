@@ -0,0 +1,376 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+const (
+	goModFileMode = 0o600
+	k6MainModule  = "xk6.local/sync"
+	// zeroPseudoVersion is the version the go command uses for requirements satisfied by a replacement.
+	zeroPseudoVersion = "v0.0.0-00010101000000-000000000000"
+)
+
+// buildModule is a module of a build list, as printed by go list -m -json.
+type buildModule struct {
+	Path     string          `json:"Path"`
+	Version  string          `json:"Version"`
+	Main     bool            `json:"Main"`
+	Replace  *module.Version `json:"Replace"`
+	Indirect bool            `json:"Indirect"`
+}
+
+// graphChanges returns the changes needed to align the build list of the extension in dir with the
+// build list of k6. The build lists are the versions selected by minimal version selection over the
+// whole module graph, so the modules the extension or k6 only depend on indirectly are aligned too.
+// Every module used by both is reported if the selected versions differ, with the reason of the change.
+// The build lists are loaded with the go command, or from the go.mod files served by the Go proxy
+// if edit is true, so editing go.mod directly does not need the Go toolchain.
+func graphChanges(
+	ctx context.Context,
+	dir string,
+	extModfile *modfile.File,
+	k6ModulePath, k6Version string,
+	k6Modfile *modfile.File,
+	edit bool,
+) ([]*Change, error) {
+	k6Main, err := k6BuildModfile(k6ModulePath, k6Version, k6Modfile)
+	if err != nil {
+		return nil, err
+	}
+
+	buildLists := goBuildLists
+	if edit {
+		buildLists = proxyBuildLists
+	}
+
+	extList, extGraph, k6List, err := buildLists(ctx, dir, extModfile, k6Main)
+	if err != nil {
+		return nil, err
+	}
+
+	direct := make(map[string]bool)
+
+	for _, req := range extModfile.Require {
+		if !req.Indirect {
+			direct[req.Mod.Path] = true
+		}
+	}
+
+	k6Versions := make(map[string]string, len(k6List))
+
+	for _, mod := range k6List {
+		if !mod.Main {
+			k6Versions[mod.Path] = mod.Version
+		}
+	}
+
+	changes := make([]*Change, 0)
+
+	for _, mod := range extList {
+		// modules replaced by the extension are not affected by their required version
+		if mod.Main || mod.Replace != nil {
+			continue
+		}
+
+		to, found := k6Versions[mod.Path]
+		if !found || to == mod.Version {
+			continue
+		}
+
+		changes = append(changes, &Change{
+			Module:   mod.Path,
+			From:     mod.Version,
+			To:       to,
+			Indirect: !direct[mod.Path],
+			Reason:   changeReason(mod, to, k6ModulePath, k6Version, extGraph),
+		})
+	}
+
+	return changes, nil
+}
+
+// changeReason explains why the module has to change: which module requires the version
+// selected for the extension, and that k6 selects a different one.
+func changeReason(mod *buildModule, to, k6ModulePath, k6Version string, graph map[string][]string) string {
+	if mod.Path == k6ModulePath {
+		return fmt.Sprintf("syncing with k6 %s", k6Version)
+	}
+
+	requiredBy := "go.mod"
+
+	if requirers := graph[mod.Path+"@"+mod.Version]; len(requirers) != 0 {
+		requiredBy = requirers[0]
+
+		for _, requirer := range requirers {
+			// the main module is printed without version
+			if !strings.Contains(requirer, "@") {
+				requiredBy = "go.mod"
+
+				break
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s required by %s, k6 %s selects %s", mod.Version, requiredBy, k6Version, to)
+}
+
+// goBuildLists returns the build list and the module graph of the extension in dir, and the build list
+// of the k6 build module, loaded with the go command.
+func goBuildLists(
+	ctx context.Context, dir string, extModfile *modfile.File, k6Main *modfile.File,
+) ([]*buildModule, map[string][]string, []*buildModule, error) {
+	tmpdir, err := os.MkdirTemp("", "xk6-sync-*") //nolint:forbidigo
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	defer func() {
+		_ = os.RemoveAll(tmpdir) //nolint:forbidigo
+	}()
+
+	extList, extGraph, err := extensionBuildList(ctx, dir, extModfile, tmpdir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	k6List, err := k6BuildList(ctx, tmpdir, k6Main)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return extList, extGraph, k6List, nil
+}
+
+// proxyBuildLists returns the build list and the module graph of the extension in dir, and the build list
+// of the k6 build module, computed from the go.mod files served by the Go proxy.
+func proxyBuildLists(
+	ctx context.Context, dir string, extModfile *modfile.File, k6Main *modfile.File,
+) ([]*buildModule, map[string][]string, []*buildModule, error) {
+	extList, extGraph, err := proxyBuildList(ctx, dir, extModfile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	k6List, _, err := proxyBuildList(ctx, "", k6Main)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return extList, extGraph, k6List, nil
+}
+
+// extensionBuildList returns the build list and the module graph of the extension in dir, with the
+// given go.mod. A copy of go.mod and go.sum is used, so the extension's files are not modified while
+// the missing checksums are added.
+func extensionBuildList(
+	ctx context.Context, dir string, extModfile *modfile.File, tmpdir string,
+) ([]*buildModule, map[string][]string, error) {
+	modfilePath := filepath.Join(tmpdir, "ext.mod")
+
+	if err := copyModFiles(dir, extModfile, modfilePath); err != nil {
+		return nil, nil, err
+	}
+
+	list, err := goListBuildList(ctx, dir, modfilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := goModCommand(ctx, dir, "mod", "graph", "-modfile="+modfilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the graph maps each module version to the module versions requiring it
+	graph := make(map[string][]string)
+
+	for line := range strings.Lines(string(out)) {
+		from, to, found := strings.Cut(strings.TrimSpace(line), " ")
+		if found {
+			graph[to] = append(graph[to], from)
+		}
+	}
+
+	for _, requirers := range graph {
+		sort.Strings(requirers)
+	}
+
+	return list, graph, nil
+}
+
+// k6BuildModfile returns the go.mod of a module depending on k6, which is how k6 is built with extensions.
+// Forks declaring the original module path are used through a replace directive, like in a build.
+func k6BuildModfile(k6ModulePath, k6Version string, k6Modfile *modfile.File) (*modfile.File, error) {
+	mf := new(modfile.File)
+
+	if err := mf.AddModuleStmt(k6MainModule); err != nil {
+		return nil, err
+	}
+
+	if k6Modfile.Go != nil {
+		if err := mf.AddGoStmt(k6Modfile.Go.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	declared := k6ModulePath
+	if base, _, _ := module.SplitPathVersion(k6ModulePath); base != k6BaseModule && k6Modfile.Module != nil {
+		declared = k6Modfile.Module.Mod.Path
+	}
+
+	if declared == k6ModulePath {
+		if err := mf.AddRequire(k6ModulePath, k6Version); err != nil {
+			return nil, err
+		}
+
+		return mf, nil
+	}
+
+	if err := mf.AddRequire(declared, zeroPseudoVersion); err != nil {
+		return nil, err
+	}
+
+	if err := mf.AddReplace(declared, "", k6ModulePath, k6Version); err != nil {
+		return nil, err
+	}
+
+	return mf, nil
+}
+
+// k6BuildList returns the build list of the k6 build module, loaded with the go command.
+func k6BuildList(ctx context.Context, tmpdir string, k6Main *modfile.File) ([]*buildModule, error) {
+	data, err := k6Main.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	k6dir := filepath.Join(tmpdir, "k6")
+
+	if err := os.Mkdir(k6dir, cacheDirPerm); err != nil { //nolint:forbidigo
+		return nil, err
+	}
+
+	modfilePath := filepath.Join(k6dir, modFile)
+
+	if err := os.WriteFile(modfilePath, data, goModFileMode); err != nil { //nolint:forbidigo
+		return nil, err
+	}
+
+	return goListBuildList(ctx, k6dir, modfilePath)
+}
+
+// BuildList returns the module versions selected by minimal version selection for a main module with the given
+// go.mod, loaded with the go command. It is the whole module graph, not only the modules compiled into a binary
+// for one platform. The main module and the replaced modules are left out.
+func BuildList(ctx context.Context, mf *modfile.File) ([]module.Version, error) {
+	tmpdir, err := os.MkdirTemp("", "xk6-build-list-*") //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = os.RemoveAll(tmpdir) //nolint:forbidigo
+	}()
+
+	list, err := k6BuildList(ctx, tmpdir, mf)
+	if err != nil {
+		return nil, err
+	}
+
+	mods := make([]module.Version, 0, len(list))
+
+	for _, mod := range list {
+		if !mod.Main && mod.Replace == nil {
+			mods = append(mods, module.Version{Path: mod.Path, Version: mod.Version})
+		}
+	}
+
+	return mods, nil
+}
+
+func copyModFiles(dir string, extModfile *modfile.File, modfilePath string) error {
+	data, err := extModfile.Format()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(modfilePath, data, goModFileMode); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	sum, err := os.ReadFile(filepath.Join(dir, "go.sum")) //nolint:forbidigo,gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(strings.TrimSuffix(modfilePath, ".mod")+".sum", sum, goModFileMode) //nolint:forbidigo
+}
+
+// goListBuildList returns the build list of the module in dir, using the given go.mod file.
+func goListBuildList(ctx context.Context, dir, modfilePath string) ([]*buildModule, error) {
+	out, err := goModCommand(ctx, dir, "list", "-mod=mod", "-modfile="+modfilePath, "-m", "-json", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*buildModule
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+
+	for {
+		mod := new(buildModule)
+
+		err := dec.Decode(mod)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, mod)
+	}
+
+	return list, nil
+}
+
+func goModCommand(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	slog.Debug("Loading module graph", "dir", dir, "args", args)
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off") //nolint:forbidigo
+
+	var stderr bytes.Buffer
+
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
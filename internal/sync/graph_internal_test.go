@@ -0,0 +1,263 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// newModuleProxy writes the go.mod files (keyed by module@version) into a file:// Go proxy,
+// and configures the go command to use it with an empty module cache. The module and go
+// directives are added, unless the content starts with its own module directive.
+func newModuleProxy(t *testing.T, mods map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for key, content := range mods {
+		path, version, _ := strings.Cut(key, "@")
+
+		escaped, err := module.EscapePath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions := filepath.Join(dir, filepath.FromSlash(escaped), "@v")
+		if err := os.MkdirAll(versions, 0o750); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(content, "module ") {
+			content = "module " + path + "\n\ngo 1.21\n" + content
+		}
+
+		files := map[string]string{
+			version + ".mod":  content,
+			version + ".info": modInfo(version),
+		}
+
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(versions, name), []byte(data), 0o600); err != nil { //nolint:forbidigo
+				t.Fatal(err)
+			}
+		}
+	}
+
+	proxy := filepath.ToSlash(dir)
+	if !strings.HasPrefix(proxy, "/") {
+		proxy = "/" + proxy // windows drive letter
+	}
+
+	t.Setenv("GOPROXY", "file://"+proxy)
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOTOOLCHAIN", "local")
+}
+
+func writeExtension(t *testing.T, gomod string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, modFile), []byte(gomod), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestSync_Transitive(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"go.k6.io/k6@v1.0.0":              "require github.com/grafana/sobek v1.0.0\n",
+		"go.k6.io/k6@v1.2.0":              "require github.com/grafana/sobek v1.1.0\n",
+		"github.com/grafana/sobek@v1.0.0": "",
+		"github.com/grafana/sobek@v1.1.0": "",
+		"github.com/grafana/sobek@v1.3.0": "",
+		"github.com/example/lib@v1.0.0":   "require github.com/grafana/sobek v1.3.0\n",
+	})
+
+	dir := writeExtension(t, "module example.com/xk6-ext\n\ngo 1.21\n\n"+
+		"require (\n\tgithub.com/example/lib v1.0.0\n\tgo.k6.io/k6 v1.0.0\n)\n")
+
+	result, err := Sync(t.Context(), dir, &Options{K6Version: "v1.2.0", DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changes := make(map[string]*Change)
+	for _, change := range result.Changes {
+		changes[change.Module] = change
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+
+	sobek := changes["github.com/grafana/sobek"]
+	if sobek == nil || sobek.From != "v1.3.0" || sobek.To != "v1.1.0" || !sobek.Indirect {
+		t.Errorf("expected indirect change of sobek from v1.3.0 to v1.1.0, got %+v", sobek)
+	}
+
+	if sobek != nil && !strings.Contains(sobek.Reason, "github.com/example/lib@v1.0.0") {
+		t.Errorf("expected the requiring module in the reason, got %q", sobek.Reason)
+	}
+
+	k6 := changes[k6BaseModule]
+	if k6 == nil || k6.To != "v1.2.0" || k6.Indirect || k6.Line != 7 {
+		t.Errorf("expected direct change of k6 to v1.2.0 on line 7, got %+v", k6)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, modFile)); strings.Contains(string(data), "sobek") { //nolint:forbidigo
+		t.Error("expected go.mod not to be modified by a dry run")
+	}
+}
+
+func TestSync_Fork(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"github.com/acme/k6/v2@v2.1.0":    "require github.com/grafana/sobek v0.0.2\n",
+		"go.k6.io/k6/v2@v2.1.0":           "require github.com/grafana/sobek v0.0.9\n",
+		"github.com/grafana/sobek@v0.0.1": "",
+		"github.com/grafana/sobek@v0.0.2": "",
+		"github.com/grafana/sobek@v0.0.9": "",
+	})
+
+	dir := writeExtension(t, "module example.com/xk6-ext\n\ngo 1.21\n\n"+
+		"require (\n\tgithub.com/grafana/sobek v0.0.1\n\tgo.k6.io/k6/v2 v2.1.0\n)\n")
+
+	result, err := Sync(t.Context(), dir, &Options{K6Repo: "github.com/acme/k6", K6Version: "v2.1.0", DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.K6Module != "github.com/acme/k6/v2" {
+		t.Errorf("expected the fork module, got %s", result.K6Module)
+	}
+
+	if len(result.Changes) != 1 || result.Changes[0].From != "v0.0.9" || result.Changes[0].To != "v0.0.2" {
+		t.Errorf("expected sync with the fork's go.mod, got %+v", result.Changes)
+	}
+}
+
+func TestSync_Edit(t *testing.T) {
+	mods := map[string]string{
+		"go.k6.io/k6@v1.0.0":              "require github.com/grafana/sobek v1.0.0\n",
+		"go.k6.io/k6@v1.2.0":              "require github.com/grafana/sobek v1.1.0\n",
+		"github.com/grafana/sobek@v1.0.0": "",
+		"github.com/grafana/sobek@v1.1.0": "",
+		"github.com/grafana/sobek@v1.3.0": "",
+		"github.com/example/lib@v1.0.0":   "require github.com/grafana/sobek v1.3.0\n",
+	}
+
+	newModuleProxy(t, mods)
+
+	gomod := "module example.com/xk6-ext\n\ngo 1.21\n\n" +
+		"require (\n\tgithub.com/example/lib v1.0.0\n\tgo.k6.io/k6 v1.0.0 // pinned\n)\n"
+
+	dir := writeExtension(t, gomod)
+
+	var gosum strings.Builder
+
+	for _, key := range []string{"github.com/example/lib@v1.0.0", "github.com/grafana/sobek@v1.3.0", "go.k6.io/k6@v1.0.0"} {
+		path, version, _ := strings.Cut(key, "@")
+
+		hash, err := goModHash([]byte("module " + path + "\n\ngo 1.21\n" + mods[key]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if path == "github.com/grafana/sobek" {
+			gosum.WriteString(path + " " + version + " h1:zip130=\n")
+		}
+
+		gosum.WriteString(path + " " + version + "/go.mod " + hash + "\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(gosum.String()), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	// a dry run does not need the zip hash of the new sobek version
+	t.Setenv("GOSUMDB", "off")
+
+	result, err := Sync(t.Context(), dir, &Options{K6Version: "v1.2.0", Edit: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected dry run error: %v", err)
+	}
+
+	if len(result.Changes) != 2 {
+		t.Errorf("expected 2 changes in dry run, got %+v", result.Changes)
+	}
+
+	// the zip hash of the new sobek version comes from the module cache
+	ziphash, err := moduleCacheFile("github.com/grafana/sobek", "v1.1.0", ".ziphash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ziphash), 0o750); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(ziphash, []byte("h1:zip110=\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	result, err = Sync(t.Context(), dir, &Options{K6Version: "v1.2.0", Edit: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Changes) != 2 || len(result.Secondary) != 0 {
+		t.Errorf("expected 2 changes without secondary changes, got %+v %+v", result.Changes, result.Secondary)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, modFile)) //nolint:forbidigo
+	if !strings.Contains(string(data), "\tgo.k6.io/k6 v1.2.0 // pinned\n") ||
+		!strings.Contains(string(data), "github.com/grafana/sobek v1.1.0 // indirect") {
+		t.Errorf("unexpected go.mod:\n%s", data)
+	}
+
+	data, _ = os.ReadFile(filepath.Join(dir, "go.sum")) //nolint:forbidigo
+	if !strings.Contains(string(data), "github.com/grafana/sobek v1.1.0 h1:zip110=\ngithub.com/grafana/sobek v1.1.0/go.mod h1:") ||
+		!strings.Contains(string(data), "go.k6.io/k6 v1.2.0/go.mod h1:") ||
+		strings.Contains(string(data), "go.k6.io/k6 v1.2.0 h1:") {
+		t.Errorf("unexpected go.sum:\n%s", data)
+	}
+}
+
+func TestTidyChanges(t *testing.T) {
+	t.Parallel()
+
+	mf, err := modfile.Parse(modFile, []byte("module ext\n\ngo 1.21\n\nrequire example.com/foo v1.0.0\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newMod, err := modfile.Parse(modFile, []byte("module example.com/bar\n\ngo 1.23\n\n"+
+		"require (\n\texample.com/foo v1.1.0\n\texample.com/unlisted v1.0.0\n)\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newMod.Module.Mod.Version = "v2.0.0"
+
+	changes := tidyChanges(mf, []*modfile.File{newMod})
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 secondary changes, got %+v", changes)
+	}
+
+	if changes[0].Module != "example.com/foo" || changes[0].To != "v1.1.0" || changes[0].Line != 5 ||
+		changes[0].Reason != "v1.1.0 required by example.com/bar@v2.0.0" {
+		t.Errorf("unexpected module change: %+v", changes[0])
+	}
+
+	if changes[1].Module != goDirective || changes[1].From != "1.21" || changes[1].To != "1.23" {
+		t.Errorf("unexpected go directive change: %+v", changes[1])
+	}
+}
@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	gosync "sync"
+)
+
+// majorProbeBatch is the number of major versions probed concurrently.
+const majorProbeBatch = 4
+
+// major is the result of probing a major version module path.
+type major[T any] struct {
+	path  string
+	value T
+}
+
+// probeMajors probes the major version module paths of the base module (base/v2, base/v3, …) in order,
+// until maxAbsent consecutive major versions do not exist or until the result of an existing major version
+// satisfies stop (if not nil). The probes run concurrently in batches, so the proxy round trips do not add up.
+// The results of the existing major versions are returned in order.
+func probeMajors[T any](
+	ctx context.Context,
+	base string,
+	maxAbsent int,
+	probe func(ctx context.Context, path string) (T, bool),
+	stop func(value T) bool,
+) []major[T] {
+	type result struct {
+		value  T
+		exists bool
+	}
+
+	var found []major[T]
+
+	absent := 0
+
+	for first := 2; ; first += majorProbeBatch {
+		results := make([]result, majorProbeBatch)
+
+		var wg gosync.WaitGroup
+
+		for idx := range results {
+			wg.Go(func() {
+				path := fmt.Sprintf("%s/v%d", base, first+idx)
+				value, exists := probe(ctx, path)
+				results[idx] = result{value: value, exists: exists}
+			})
+		}
+
+		wg.Wait()
+
+		for idx, res := range results {
+			if !res.exists {
+				absent++
+
+				if absent >= maxAbsent {
+					return found
+				}
+
+				continue
+			}
+
+			absent = 0
+
+			found = append(found, major[T]{path: fmt.Sprintf("%s/v%d", base, first+idx), value: res.value})
+
+			if stop != nil && stop(res.value) {
+				return found
+			}
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	// ErrInvalidMajor is returned when the target of a migration is not a major version (e.g. v2).
+	ErrInvalidMajor = errors.New("invalid major version")
+	// ErrK6NotRequired is returned when the extension to migrate does not require k6.
+	ErrK6NotRequired = errors.New("k6 is not required in go.mod")
+)
+
+// MigrateResult represents the result of a migration to another k6 major version.
+type MigrateResult struct {
+	// From is the k6 module path the extension required before the migration.
+	From string `json:"from,omitempty"`
+	// K6Module is the k6 module path the extension has been migrated to (e.g. go.k6.io/k6/v2).
+	K6Module string `json:"k6_module,omitempty"`
+	// K6Version is the k6 version required after the migration.
+	K6Version string `json:"k6_version,omitempty"`
+	// Files is the list of Go source files with rewritten imports, relative to the extension directory.
+	Files []string `json:"files,omitempty"`
+	// Imports is the number of rewritten import declarations.
+	Imports int `json:"imports,omitempty"`
+	// Sync is the result of the synchronization with the k6 version.
+	Sync *Result `json:"sync,omitempty"`
+}
+
+// Migrate moves the extension in the specified directory to the given k6 major version (e.g. v2).
+// The go.k6.io/k6 imports (of any major version) in the Go sources are rewritten to the target module
+// path, preserving the formatting of the files, and the k6 requirement in go.mod is replaced.
+// The dependencies are then synchronized with the k6 version, which is opts.K6Version if set,
+// or the latest version of the target major version otherwise.
+func Migrate(ctx context.Context, dir, major string, opts *Options) (*MigrateResult, error) {
+	target, err := k6ModuleForMajor(major)
+	if err != nil {
+		return nil, err
+	}
+
+	extModfile, err := loadModfile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	from, _, found := findK6Require(extModfile)
+	if !found {
+		return nil, ErrK6NotRequired
+	}
+
+	k6Version, err := migrateVersion(ctx, target, opts.K6Version)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Migrating k6", "from", from, "to", target, "version", k6Version)
+
+	result := &MigrateResult{From: from, K6Module: target, K6Version: k6Version}
+
+	rewrites, err := rewriteImports(dir, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rewrite := range rewrites {
+		result.Files = append(result.Files, rewrite.file)
+		result.Imports += rewrite.count
+	}
+
+	for _, r := range extModfile.Replace {
+		if base, _, _ := module.SplitPathVersion(r.Old.Path); base == k6BaseModule {
+			slog.Warn("The replace directive of k6 is not migrated", "module", r.Old.Path)
+		}
+	}
+
+	syncOpts := *opts
+	syncOpts.K6Repo = target
+	syncOpts.K6Version = k6Version
+
+	if opts.DryRun {
+		// nothing is written, the sync is computed on the migrated go.mod edited in memory
+		if err := replaceRequire(extModfile, from, target, k6Version); err != nil {
+			return nil, err
+		}
+
+		extModfile.Cleanup()
+
+		result.Sync, err = syncModfile(ctx, dir, extModfile, &syncOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}
+
+	if err := writeRewrites(dir, rewrites); err != nil {
+		return nil, err
+	}
+
+	if err := migrateRequire(ctx, dir, extModfile, from, target, k6Version, opts); err != nil {
+		return nil, err
+	}
+
+	result.Sync, err = Sync(ctx, dir, &syncOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// k6ModuleForMajor returns the k6 module path of the major version (e.g. go.k6.io/k6/v2 for v2).
+func k6ModuleForMajor(major string) (string, error) {
+	if !semver.IsValid(major) || semver.Major(major) != major {
+		return "", fmt.Errorf("%w: %q, expected a major version like v2", ErrInvalidMajor, major)
+	}
+
+	if major == "v0" || major == "v1" {
+		return k6BaseModule, nil
+	}
+
+	return k6BaseModule + "/" + major, nil
+}
+
+// migrateVersion returns the k6 version to migrate to: the requested version, which must belong
+// to the target module, or the latest version of the target module.
+func migrateVersion(ctx context.Context, target, version string) (string, error) {
+	if len(version) == 0 || version == "latest" {
+		return getLatestVersion(ctx, target)
+	}
+
+	path, err := ResolveModuleForVersion(ctx, k6BaseModule, version)
+	if err != nil {
+		return "", err
+	}
+
+	if path != target {
+		return "", fmt.Errorf("%w: k6 version %s belongs to %s, not %s", ErrInvalidMajor, version, path, target)
+	}
+
+	return version, nil
+}
+
+// migrateRequire replaces the k6 requirement in go.mod with the target module. With opts.Edit the go.sum
+// lines of the target module are added directly, otherwise go get is used to record the new requirement.
+func migrateRequire(
+	ctx context.Context,
+	dir string,
+	mf *modfile.File,
+	from, target, version string,
+	opts *Options,
+) error {
+	if err := replaceRequire(mf, from, target, version); err != nil {
+		return err
+	}
+
+	if !opts.Edit {
+		mf.Cleanup()
+
+		if err := writeModfile(dir, mf); err != nil {
+			return err
+		}
+
+		return goGet(ctx, opts, []*Change{{Module: target, To: version}})
+	}
+
+	sums, err := readSums(filepath.Join(dir, sumFile))
+	if err != nil {
+		return err
+	}
+
+	// k6 always provides packages to the extension, so the module zip hash is needed too
+	if _, err := editRequire(ctx, mf, &Change{Module: target, To: version}, sums, true); err != nil {
+		return err
+	}
+
+	mf.Cleanup()
+
+	if err := writeModfile(dir, mf); err != nil {
+		return err
+	}
+
+	return writeFilePreserveMode(filepath.Join(dir, sumFile), formatSums(sums))
+}
+
+// replaceRequire replaces the k6 requirement of the go.mod file with the target module and version.
+func replaceRequire(mf *modfile.File, from, target, version string) error {
+	if from != target {
+		if err := mf.DropRequire(from); err != nil {
+			return err
+		}
+	}
+
+	return mf.AddRequire(target, version)
+}
+
+func writeModfile(dir string, mf *modfile.File) error {
+	data, err := mf.Format()
+	if err != nil {
+		return err
+	}
+
+	return writeFilePreserveMode(filepath.Join(dir, modFile), data)
+}
+
+// importRewrite is a Go source file with rewritten imports.
+type importRewrite struct {
+	// file is the path of the file, relative to the extension directory, with forward slashes.
+	file string
+	// data is the rewritten content of the file.
+	data []byte
+	// count is the number of rewritten imports.
+	count int
+}
+
+// rewriteImports rewrites the k6 imports of the Go source files of the module in dir to the target
+// k6 module path, in memory. Nested modules, vendor and testdata directories, and directories ignored
+// by the go command are skipped. Nothing is written, so a file that cannot be parsed leaves every
+// file untouched (see writeRewrites).
+func rewriteImports(dir, target string) ([]*importRewrite, error) {
+	var rewrites []*importRewrite
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != dir && skipDir(path, entry.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		src, err := os.ReadFile(filepath.Clean(path)) //nolint:forbidigo
+		if err != nil {
+			return err
+		}
+
+		out, count, err := rewriteFileImports(path, src, target)
+		if err != nil || count == 0 {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		slog.Debug("Rewriting imports", "file", rel, "count", count)
+
+		rewrites = append(rewrites, &importRewrite{file: filepath.ToSlash(rel), data: out, count: count})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rewrites, nil
+}
+
+// writeRewrites writes the rewritten Go source files of the module in dir.
+func writeRewrites(dir string, rewrites []*importRewrite) error {
+	for _, rewrite := range rewrites {
+		if err := writeFilePreserveMode(filepath.Join(dir, filepath.FromSlash(rewrite.file)), rewrite.data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// skipDir returns true if the directory does not belong to the module's packages.
+func skipDir(path, name string) bool {
+	if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(path, modFile)) //nolint:forbidigo
+
+	return err == nil
+}
+
+// rewriteFileImports rewrites the k6 import paths of a Go source file to the target k6 module path.
+// The source is only located with go/parser, the import path literals are replaced in place,
+// so the rest of the file (formatting, comments, import grouping) is preserved byte by byte.
+func rewriteFileImports(filename string, src []byte, target string) ([]byte, int, error) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var out bytes.Buffer
+
+	last, count := 0, 0
+
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		newPath, changed := migrateImportPath(path, target)
+		if !changed {
+			continue
+		}
+
+		start := fset.Position(spec.Path.Pos()).Offset
+		end := fset.Position(spec.Path.End()).Offset
+
+		out.Write(src[last:start])
+		out.WriteString(strconv.Quote(newPath))
+
+		last = end
+		count++
+	}
+
+	if count == 0 {
+		return src, 0, nil
+	}
+
+	out.Write(src[last:])
+
+	return out.Bytes(), count, nil
+}
+
+// migrateImportPath returns the import path of a package of any k6 major version
+// (go.k6.io/k6/js/modules, go.k6.io/k6/v2/js/modules, …) in the target k6 module.
+// It returns false if the path is not a k6 package or it already belongs to the target module.
+func migrateImportPath(path, target string) (string, bool) {
+	rest, found := strings.CutPrefix(path, k6BaseModule)
+	if !found || (len(rest) != 0 && rest[0] != '/') {
+		return "", false
+	}
+
+	current := k6BaseModule
+
+	if elem, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/"); len(elem) != 0 {
+		if _, pathMajor, ok := module.SplitPathVersion(k6BaseModule + "/" + elem); ok && len(pathMajor) != 0 {
+			current += pathMajor
+			rest = strings.TrimPrefix(rest, pathMajor)
+		}
+	}
+
+	if current == target {
+		return "", false
+	}
+
+	return target + rest, true
+}
@@ -0,0 +1,241 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMigrateImportPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path     string
+		target   string
+		expected string
+		changed  bool
+	}{
+		{path: "go.k6.io/k6/js/modules", target: "go.k6.io/k6/v2", expected: "go.k6.io/k6/v2/js/modules", changed: true},
+		{path: "go.k6.io/k6", target: "go.k6.io/k6/v2", expected: "go.k6.io/k6/v2", changed: true},
+		{path: "go.k6.io/k6/v2/lib", target: "go.k6.io/k6/v3", expected: "go.k6.io/k6/v3/lib", changed: true},
+		{path: "go.k6.io/k6/v2/lib", target: "go.k6.io/k6", expected: "go.k6.io/k6/lib", changed: true},
+		{path: "go.k6.io/k6/v2/lib", target: "go.k6.io/k6/v2"},
+		{path: "go.k6.io/k6foo/lib", target: "go.k6.io/k6/v2"},
+		{path: "github.com/grafana/sobek", target: "go.k6.io/k6/v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+"->"+tt.target, func(t *testing.T) {
+			t.Parallel()
+
+			got, changed := migrateImportPath(tt.path, tt.target)
+			if changed != tt.changed || got != tt.expected {
+				t.Errorf("expected %q, %v, got %q, %v", tt.expected, tt.changed, got, changed)
+			}
+		})
+	}
+}
+
+func TestRewriteFileImports(t *testing.T) {
+	t.Parallel()
+
+	const src = `package foo
+
+import (
+	"fmt"
+
+	"github.com/grafana/sobek"
+	k6modules "go.k6.io/k6/js/modules" // the module API
+	"go.k6.io/k6/lib"
+)
+
+var _ = fmt.Sprint
+`
+
+	const expected = `package foo
+
+import (
+	"fmt"
+
+	"github.com/grafana/sobek"
+	k6modules "go.k6.io/k6/v2/js/modules" // the module API
+	"go.k6.io/k6/v2/lib"
+)
+
+var _ = fmt.Sprint
+`
+
+	out, count, err := rewriteFileImports("foo.go", []byte(src), "go.k6.io/k6/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("expected 2 rewritten imports, got %d", count)
+	}
+
+	if string(out) != expected {
+		t.Errorf("unexpected source:\n%s", out)
+	}
+}
+
+func TestRewriteImports(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod":                "module github.com/grafana/xk6-foo\n",
+		"foo.go":                "package foo\n\nimport _ \"go.k6.io/k6/js/modules\"\n",
+		"bar.go":                "package foo\n\nimport _ \"fmt\"\n",
+		"internal/baz/baz.go":   "package baz\n\nimport _ \"go.k6.io/k6/lib\"\n",
+		"testdata/skip.go":      "package skip\n\nimport _ \"go.k6.io/k6/lib\"\n",
+		"vendor/skip/skip.go":   "package skip\n\nimport _ \"go.k6.io/k6/lib\"\n",
+		"examples/go.mod":       "module example\n",
+		"examples/example.go":   "package example\n\nimport _ \"go.k6.io/k6/lib\"\n",
+		".github/tools/tool.go": "package tool\n\nimport _ \"go.k6.io/k6/lib\"\n",
+	}
+
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filename, []byte(content), 0o600); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+	}
+
+	rewrites, err := rewriteImports(dir, "go.k6.io/k6/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make([]string, 0, len(rewrites))
+	count := 0
+
+	for _, rewrite := range rewrites {
+		changed = append(changed, rewrite.file)
+		count += rewrite.count
+	}
+
+	if !slices.Equal(changed, []string{"foo.go", "internal/baz/baz.go"}) || count != 2 {
+		t.Fatalf("unexpected files: %v (%d imports)", changed, count)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "foo.go")) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != files["foo.go"] {
+		t.Error("file changed before writing the rewrites")
+	}
+
+	if err := writeRewrites(dir, rewrites); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "internal", "baz", "baz.go")) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "package baz\n\nimport _ \"go.k6.io/k6/v2/lib\"\n" {
+		t.Errorf("unexpected source:\n%s", data)
+	}
+}
+
+func TestMigrate_InvalidSource(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"go.k6.io/k6@v1.0.0":    "",
+		"go.k6.io/k6/v2@v2.0.0": "",
+	})
+
+	dir := writeExtension(t, "module example.com/xk6-ext\n\ngo 1.21\n\nrequire go.k6.io/k6 v1.0.0\n")
+
+	const src = "package foo\n\nimport _ \"go.k6.io/k6/js/modules\"\n"
+
+	for name, content := range map[string]string{"a.go": src, "b.go": "package foo\n\nimport (\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil { //nolint:forbidigo
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Migrate(t.Context(), dir, "v2", &Options{K6Version: "v2.0.0"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.go")) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != src {
+		t.Errorf("file rewritten despite the parse error:\n%s", data)
+	}
+}
+
+func TestMigrate_DryRun(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"go.k6.io/k6@v1.0.0":              "require github.com/grafana/sobek v1.3.0\n",
+		"go.k6.io/k6/v2@v2.0.0":           "require github.com/grafana/sobek v1.1.0\n",
+		"github.com/grafana/sobek@v1.1.0": "",
+		"github.com/grafana/sobek@v1.2.0": "",
+		"github.com/grafana/sobek@v1.3.0": "",
+		"github.com/example/lib@v1.0.0":   "require github.com/grafana/sobek v1.2.0\n",
+	})
+
+	const gomod = "module example.com/xk6-ext\n\ngo 1.21\n\n" +
+		"require (\n\tgithub.com/example/lib v1.0.0\n\tgo.k6.io/k6 v1.0.0\n)\n"
+
+	dir := writeExtension(t, gomod)
+
+	result, err := Migrate(t.Context(), dir, "v2", &Options{K6Version: "v2.0.0", DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the sync is computed on the migrated go.mod, without the sobek version required by k6 v1.0.0
+	if result.Sync == nil || len(result.Sync.Changes) != 1 {
+		t.Fatalf("expected 1 change, got %+v", result.Sync)
+	}
+
+	if change := result.Sync.Changes[0]; change.Module != "github.com/grafana/sobek" ||
+		change.From != "v1.2.0" || change.To != "v1.1.0" {
+		t.Errorf("expected change of sobek from v1.2.0 to v1.1.0, got %+v", change)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, modFile)) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != gomod {
+		t.Errorf("go.mod changed in dry run:\n%s", data)
+	}
+}
+
+func TestK6ModuleForMajor(t *testing.T) {
+	t.Parallel()
+
+	path, err := k6ModuleForMajor("v2")
+	if err != nil || path != "go.k6.io/k6/v2" {
+		t.Errorf("expected go.k6.io/k6/v2, got %q (%v)", path, err)
+	}
+
+	path, err = k6ModuleForMajor("v1")
+	if err != nil || path != "go.k6.io/k6" {
+		t.Errorf("expected go.k6.io/k6, got %q (%v)", path, err)
+	}
+
+	for _, major := range []string{"2", "v2.0.0", "latest"} {
+		if _, err := k6ModuleForMajor(major); !errors.Is(err, ErrInvalidMajor) {
+			t.Errorf("expected ErrInvalidMajor for %q, got %v", major, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package sync
+
+import (
+	"context"
+	"go/version"
+	"os"
+	"path/filepath"
+	"sort"
+	gosync "sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// graphConcurrency is the number of go.mod files downloaded concurrently while loading a module graph.
+	graphConcurrency = 8
+	// prunedGoVersion is the first Go version with module graph pruning.
+	prunedGoVersion = "go1.17"
+)
+
+// graphNode is a module version to load the requirements of. Unpruned nodes are loaded with their
+// whole transitive module graph, like the go command does for modules without graph pruning.
+type graphNode struct {
+	mod      module.Version
+	unpruned bool
+}
+
+// moduleGraph is a module graph loaded from go.mod files.
+type moduleGraph struct {
+	dir       string
+	replace   []*modfile.Replace
+	selected  map[string]string
+	requirers map[string][]string
+	// loaded contains the nodes whose requirements are already loaded.
+	loaded map[graphNode]bool
+	// required contains the module versions whose requirements are already in the graph.
+	required map[module.Version]bool
+}
+
+// proxyBuildList returns the build list and the module graph of the main module, computed with minimal
+// version selection over the go.mod files served by the Go proxy, without the go command.
+// The module graph is pruned like the go command does for modules declaring go 1.17 or later: only the
+// requirements of such modules are included, not their transitive requirements. The replace directives
+// of the main module are applied, directory replacements are relative to dir. The exclude directives
+// are not applied. The graph maps each module version to the module versions requiring it.
+func proxyBuildList(ctx context.Context, dir string, main *modfile.File) ([]*buildModule, map[string][]string, error) {
+	graph := &moduleGraph{
+		dir:       dir,
+		replace:   main.Replace,
+		selected:  make(map[string]string),
+		requirers: make(map[string][]string),
+		loaded:    make(map[graphNode]bool),
+		required:  make(map[module.Version]bool),
+	}
+
+	mainUnpruned := !isPruned(main)
+
+	queue := make([]graphNode, 0, len(main.Require))
+
+	for _, req := range main.Require {
+		graph.add(req.Mod, main.Module.Mod.Path)
+
+		queue = append(queue, graphNode{mod: req.Mod, unpruned: mainUnpruned})
+	}
+
+	for len(queue) != 0 {
+		queue = graph.unloaded(queue)
+
+		modfiles, err := graph.load(ctx, queue)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		next := make([]graphNode, 0)
+
+		for idx, node := range queue {
+			// the requirements of modules without graph pruning are loaded transitively
+			unpruned := node.unpruned || !isPruned(modfiles[idx])
+
+			// a module loaded before without its transitive requirements is only loaded again to follow them
+			again := graph.required[node.mod]
+
+			for _, req := range modfiles[idx].Require {
+				if !again {
+					graph.add(req.Mod, node.mod.String())
+				}
+
+				if unpruned {
+					next = append(next, graphNode{mod: req.Mod, unpruned: true})
+				}
+			}
+
+			graph.loaded[node] = true
+			graph.required[node.mod] = true
+		}
+
+		queue = next
+	}
+
+	for _, requirers := range graph.requirers {
+		sort.Strings(requirers)
+	}
+
+	return graph.buildList(main), graph.requirers, nil
+}
+
+// unloaded returns the nodes whose requirements are not loaded yet, without duplicates.
+// A module loaded unpruned is not loaded again pruned.
+func (g *moduleGraph) unloaded(nodes []graphNode) []graphNode {
+	seen := make(map[graphNode]bool, len(nodes))
+	unloaded := make([]graphNode, 0, len(nodes))
+
+	for _, node := range nodes {
+		if seen[node] || g.loaded[node] || g.loaded[graphNode{mod: node.mod, unpruned: true}] {
+			continue
+		}
+
+		seen[node] = true
+
+		unloaded = append(unloaded, node)
+	}
+
+	return unloaded
+}
+
+// add records the requirement of the module version by requirer and selects the highest version.
+func (g *moduleGraph) add(mod module.Version, requirer string) {
+	key := mod.String()
+
+	g.requirers[key] = append(g.requirers[key], requirer)
+
+	if semver.Compare(mod.Version, g.selected[mod.Path]) > 0 {
+		g.selected[mod.Path] = mod.Version
+	}
+}
+
+// load downloads the go.mod files of the nodes concurrently, taking the replacements into account.
+func (g *moduleGraph) load(ctx context.Context, nodes []graphNode) ([]*modfile.File, error) {
+	modfiles := make([]*modfile.File, len(nodes))
+	errs := make([]error, len(nodes))
+
+	var wg gosync.WaitGroup
+
+	limit := make(chan struct{}, graphConcurrency)
+
+	for idx, node := range nodes {
+		wg.Go(func() {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			modfiles[idx], errs[idx] = g.loadModfile(ctx, node.mod)
+		})
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return modfiles, nil
+}
+
+// loadModfile returns the go.mod file of the module version, or of its replacement.
+func (g *moduleGraph) loadModfile(ctx context.Context, mod module.Version) (*modfile.File, error) {
+	rep := g.replacement(mod)
+	if rep == nil {
+		return getModule(ctx, mod.Path, mod.Version)
+	}
+
+	if len(rep.Version) != 0 {
+		return getModule(ctx, rep.Path, rep.Version)
+	}
+
+	dir := rep.Path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(g.dir, dir)
+	}
+
+	filename := filepath.Join(dir, modFile)
+
+	data, err := os.ReadFile(filepath.Clean(filename)) //nolint:forbidigo
+	if err != nil {
+		return nil, err
+	}
+
+	return modfile.Parse(filename, data, nil)
+}
+
+// replacement returns the replacement of the module version, nil if it is not replaced.
+// A replacement of the specific version takes precedence over the replacement of every version.
+func (g *moduleGraph) replacement(mod module.Version) *module.Version {
+	var found *module.Version
+
+	for _, rep := range g.replace {
+		if rep.Old.Path != mod.Path {
+			continue
+		}
+
+		if rep.Old.Version == mod.Version {
+			return &rep.New
+		}
+
+		if len(rep.Old.Version) == 0 {
+			found = &rep.New
+		}
+	}
+
+	return found
+}
+
+// buildList returns the selected module versions, the main module first.
+func (g *moduleGraph) buildList(main *modfile.File) []*buildModule {
+	list := []*buildModule{{Path: main.Module.Mod.Path, Main: true}}
+
+	paths := make([]string, 0, len(g.selected))
+	for path := range g.selected {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		mod := &buildModule{Path: path, Version: g.selected[path]}
+
+		if rep := g.replacement(module.Version{Path: path, Version: mod.Version}); rep != nil {
+			mod.Replace = rep
+		}
+
+		list = append(list, mod)
+	}
+
+	return list
+}
+
+// isPruned returns true if the module declares a Go version with module graph pruning.
+func isPruned(mf *modfile.File) bool {
+	return mf.Go != nil && version.Compare("go"+mf.Go.Version, prunedGoVersion) >= 0
+}
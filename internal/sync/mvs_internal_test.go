@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProxyBuildList_Pruning(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		// example.com/a has no module graph pruning, its whole module graph is loaded
+		"example.com/a@v1.0.0": "module example.com/a\n\ngo 1.16\n\nrequire example.com/c v1.0.0\n",
+		"example.com/b@v1.0.0": "require example.com/e v1.0.0\n",
+		"example.com/c@v1.0.0": "require example.com/d v1.1.0\n",
+		"example.com/d@v1.1.0": "",
+		"example.com/d@v1.2.0": "",
+		// the requirements of example.com/e are pruned out
+		"example.com/e@v1.0.0": "require example.com/d v1.2.0\n",
+	})
+
+	dir := writeExtension(t, "module example.com/xk6-ext\n\ngo 1.21\n\n"+
+		"require (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0\n)\n")
+
+	extModfile, err := loadModfile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, graph, err := proxyBuildList(t.Context(), dir, extModfile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := make([]string, 0, len(list))
+	for _, mod := range list {
+		got = append(got, strings.TrimSpace(mod.Path+" "+mod.Version))
+	}
+
+	expected := []string{
+		"example.com/xk6-ext",
+		"example.com/a v1.0.0", "example.com/b v1.0.0", "example.com/c v1.0.0", "example.com/d v1.1.0", "example.com/e v1.0.0",
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected build list %v, got %v", expected, got)
+	}
+
+	if requirers := graph["example.com/d@v1.1.0"]; !reflect.DeepEqual(requirers, []string{"example.com/c@v1.0.0"}) {
+		t.Errorf("unexpected requirers of example.com/d: %v", requirers)
+	}
+
+	// the go command selects the same versions
+	goList, _, err := extensionBuildList(t.Context(), dir, extModfile, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, mod := range goList {
+		if idx < len(list) && (mod.Path != list[idx].Path || mod.Version != list[idx].Version) {
+			t.Errorf("expected %s %s as selected by the go command, got %s %s",
+				mod.Path, mod.Version, list[idx].Path, list[idx].Version)
+		}
+	}
+}
+
+func TestSync_EditWithoutToolchain(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"go.k6.io/k6@v1.0.0":              "require github.com/grafana/sobek v1.0.0\n",
+		"go.k6.io/k6@v1.2.0":              "require github.com/grafana/sobek v1.1.0\n",
+		"github.com/grafana/sobek@v1.0.0": "",
+		"github.com/grafana/sobek@v1.1.0": "",
+		"github.com/grafana/sobek@v1.3.0": "",
+		"github.com/example/lib@v1.0.0":   "require github.com/grafana/sobek v1.3.0\n",
+	})
+
+	dir := writeExtension(t, "module example.com/xk6-ext\n\ngo 1.21\n\n"+
+		"require (\n\tgithub.com/example/lib v1.0.0\n\tgo.k6.io/k6 v1.0.0\n)\n")
+
+	// the go command is not available
+	t.Setenv("PATH", t.TempDir())
+
+	result, err := Sync(t.Context(), dir, &Options{K6Version: "v1.2.0", Edit: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changes := make(map[string]*Change)
+	for _, change := range result.Changes {
+		changes[change.Module] = change
+	}
+
+	sobek := changes["github.com/grafana/sobek"]
+	if sobek == nil || sobek.From != "v1.3.0" || sobek.To != "v1.1.0" || !sobek.Indirect ||
+		!strings.Contains(sobek.Reason, "github.com/example/lib@v1.0.0") {
+		t.Errorf("expected indirect change of sobek from v1.3.0 to v1.1.0 required by lib, got %+v", sobek)
+	}
+
+	if k6 := changes[k6BaseModule]; k6 == nil || k6.To != "v1.2.0" {
+		t.Errorf("expected change of k6 to v1.2.0, got %+v", k6)
+	}
+}
@@ -6,8 +6,15 @@ import "io"
 type Options struct {
 	// K6Version is the version of k6 to use, overriding the version in go.mod.
 	K6Version string
+	// K6Repo is the k6 module path to sync with, for example a k6 fork, optionally with a /vN suffix.
+	// If empty, go.k6.io/k6 is used.
+	K6Repo string
 	// DryRun is a flag that indicates whether the sync should omit changes.
 	DryRun bool
+	// Edit is a flag that indicates whether go.mod and go.sum are edited directly, instead of running go get.
+	// Only the changed requirements are modified, and the Go toolchain is not needed: the module graph
+	// is computed from the go.mod files served by the Go proxy.
+	Edit bool
 	// Stdout is the writer to use for standard output of subcommands.
 	// If nil, output is discarded.
 	Stdout io.Writer
@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	gosync "sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// outdatedConcurrency is the number of dependencies looked up concurrently.
+const outdatedConcurrency = 8
+
+// Upgrade represents an available upgrade of a dependency.
+type Upgrade struct {
+	// Module is the module path of the version, a different major version path for major upgrades.
+	Module string `json:"module"`
+	// Version is the version to upgrade to.
+	Version string `json:"version"`
+	// Diverges is true if k6 requires a different version of the module, so xk6 sync would undo the upgrade.
+	Diverges bool `json:"diverges,omitempty"`
+}
+
+// Dependency represents a direct dependency of the extension and its available upgrades.
+type Dependency struct {
+	// Module is the module path.
+	Module string `json:"module"`
+	// Current is the version required by the extension.
+	Current string `json:"current"`
+	// K6 is the version required by k6, empty if k6 does not require the module.
+	K6 string `json:"k6,omitempty"`
+	// Patch is the latest release with the same major and minor version, nil if there is no newer one.
+	Patch *Upgrade `json:"patch,omitempty"`
+	// Minor is the latest release with the same major version, nil if there is no newer one.
+	Minor *Upgrade `json:"minor,omitempty"`
+	// Major is the latest release of the newest major version, nil if there is no newer major version.
+	Major *Upgrade `json:"major,omitempty"`
+}
+
+// OutdatedResult represents the available upgrades of the direct dependencies of an extension.
+type OutdatedResult struct {
+	// The k6 module path the upgrades are compared with.
+	K6Module string `json:"k6_module,omitempty"`
+	// The k6 version the upgrades are compared with.
+	K6Version string `json:"k6_version,omitempty"`
+	// Dependencies is the list of direct dependencies, in go.mod order.
+	Dependencies []*Dependency `json:"dependencies,omitempty"`
+}
+
+// Outdated lists the available upgrades of the direct dependencies of the extension in the specified directory.
+// The upgrades are compared with the versions required by k6: by the latest version of the k6 module in go.mod,
+// or by opts.K6Version if set. Retracted versions and pre-releases are not offered as upgrades.
+func Outdated(ctx context.Context, dir string, opts *Options) (*OutdatedResult, error) {
+	extModfile, err := loadModfile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	k6ModulePath, k6Version, err := outdatedK6Module(ctx, opts, extModfile)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Comparing upgrades with k6", "module", k6ModulePath, "version", k6Version)
+
+	k6Modfile, err := getModule(ctx, k6ModulePath, k6Version)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := map[string]string{k6ModulePath: k6Version}
+
+	for _, req := range k6Modfile.Require {
+		pinned[req.Mod.Path] = req.Mod.Version
+	}
+
+	result := &OutdatedResult{K6Module: k6ModulePath, K6Version: k6Version}
+
+	for _, req := range extModfile.Require {
+		if !req.Indirect {
+			result.Dependencies = append(result.Dependencies, &Dependency{
+				Module:  req.Mod.Path,
+				Current: req.Mod.Version,
+				K6:      pinned[req.Mod.Path],
+			})
+		}
+	}
+
+	var wg gosync.WaitGroup
+
+	limit := make(chan struct{}, outdatedConcurrency)
+
+	for _, dep := range result.Dependencies {
+		wg.Go(func() {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			findUpgrades(ctx, dep)
+		})
+	}
+
+	wg.Wait()
+
+	return result, nil
+}
+
+// outdatedK6Module returns the k6 module path and version the upgrades are compared with.
+func outdatedK6Module(ctx context.Context, opts *Options, mf *modfile.File) (string, string, error) {
+	if len(opts.K6Version) != 0 {
+		path, err := ResolveModuleForVersion(ctx, k6BaseModule, opts.K6Version)
+
+		return path, opts.K6Version, err
+	}
+
+	if path, _, found := findK6Require(mf); found {
+		version, err := getLatestVersion(ctx, path)
+
+		return path, version, err
+	}
+
+	return getOverallLatestVersionFor(ctx, k6BaseModule)
+}
+
+// findUpgrades sets the latest patch, minor and major upgrades of the dependency.
+// Dependencies whose versions cannot be listed (e.g. private modules without access) have no upgrades.
+func findUpgrades(ctx context.Context, dep *Dependency) {
+	for _, path := range upgradeModulePaths(ctx, dep.Module) {
+		versions, err := candidateVersions(ctx, path)
+		if err != nil {
+			slog.Warn("Failed to list versions", "module", path, "error", err)
+
+			return
+		}
+
+		// candidate versions are sorted newest first
+		for _, version := range versions {
+			if len(semver.Prerelease(version)) != 0 || semver.Compare(version, dep.Current) <= 0 {
+				continue
+			}
+
+			upgrade := &Upgrade{
+				Module:   path,
+				Version:  version,
+				Diverges: len(dep.K6) != 0 && (path != dep.Module || version != dep.K6),
+			}
+
+			switch {
+			case semver.Major(version) != semver.Major(dep.Current):
+				if dep.Major == nil || semver.Compare(version, dep.Major.Version) > 0 {
+					dep.Major = upgrade
+				}
+			case semver.MajorMinor(version) != semver.MajorMinor(dep.Current):
+				if dep.Minor == nil {
+					dep.Minor = upgrade
+				}
+			default:
+				if dep.Minor == nil {
+					dep.Minor = upgrade
+				}
+
+				if dep.Patch == nil {
+					dep.Patch = upgrade
+				}
+			}
+		}
+	}
+}
+
+// upgradeModulePaths returns the module paths of the dependency to look for upgrades in:
+// the module path itself and the module paths of the newer major versions.
+// gopkg.in modules use a different major version convention, only their own path is checked.
+func upgradeModulePaths(ctx context.Context, modulePath string) []string {
+	base, _, ok := module.SplitPathVersion(modulePath)
+	if !ok || strings.HasPrefix(modulePath, "gopkg.in/") {
+		return []string{modulePath}
+	}
+
+	paths := majorModulePaths(ctx, base)
+
+	for idx, path := range paths {
+		if path == modulePath {
+			return paths[idx:]
+		}
+	}
+
+	return append([]string{modulePath}, paths...)
+}
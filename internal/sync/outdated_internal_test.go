@@ -0,0 +1,36 @@
+package sync
+
+import "testing"
+
+func TestFindUpgrades(t *testing.T) {
+	newVersionsProxy(t)
+
+	dep := &Dependency{Module: "github.com/grafana/xk6-foo", Current: "v1.2.0", K6: "v1.2.3"}
+
+	findUpgrades(t.Context(), dep)
+
+	expected := map[string]*Upgrade{
+		"patch": {Module: "github.com/grafana/xk6-foo", Version: "v1.2.3"},
+		// v1.2.4 is retracted, v1.4.0-rc.1 is a pre-release
+		"minor": {Module: "github.com/grafana/xk6-foo", Version: "v1.3.0", Diverges: true},
+		"major": {Module: "github.com/grafana/xk6-foo/v2", Version: "v2.1.0", Diverges: true},
+	}
+
+	for kind, got := range map[string]*Upgrade{"patch": dep.Patch, "minor": dep.Minor, "major": dep.Major} {
+		if got == nil || *got != *expected[kind] {
+			t.Errorf("%s: expected %+v, got %+v", kind, expected[kind], got)
+		}
+	}
+}
+
+func TestFindUpgrades_UpToDate(t *testing.T) {
+	newVersionsProxy(t)
+
+	dep := &Dependency{Module: "github.com/grafana/xk6-foo/v2", Current: "v2.1.0"}
+
+	findUpgrades(t.Context(), dep)
+
+	if dep.Patch != nil || dep.Minor != nil || dep.Major != nil {
+		t.Errorf("expected no upgrades, got %+v", dep)
+	}
+}
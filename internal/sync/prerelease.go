@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// PrereleasePolicy determines whether pre-release versions (e.g. v2.0.0-rc.1) are considered
+// when resolving the latest version of a module.
+type PrereleasePolicy string
+
+const (
+	// PrereleaseAuto considers pre-releases only if the module has no releases, like the go command does.
+	// Across major versions, a pre-release of a newer major version never wins over a release.
+	PrereleaseAuto PrereleasePolicy = "auto"
+	// PrereleaseInclude considers pre-releases like releases, so the highest version wins.
+	PrereleaseInclude PrereleasePolicy = "include"
+	// PrereleaseExclude never considers pre-releases.
+	PrereleaseExclude PrereleasePolicy = "exclude"
+)
+
+var (
+	// ErrInvalidPrereleasePolicy is returned for an unknown pre-release policy.
+	ErrInvalidPrereleasePolicy = errors.New("invalid pre-release policy")
+
+	errNoRelease = errors.New("no release version")
+)
+
+// ParsePrereleasePolicy parses the name of a pre-release policy. An empty name is the auto policy.
+func ParsePrereleasePolicy(name string) (PrereleasePolicy, error) {
+	switch policy := PrereleasePolicy(name); policy {
+	case "":
+		return PrereleaseAuto, nil
+	case PrereleaseAuto, PrereleaseInclude, PrereleaseExclude:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected one of auto, include or exclude", ErrInvalidPrereleasePolicy, name)
+	}
+}
+
+type prereleaseKey struct{}
+
+// WithPrereleasePolicy returns a context that resolves the latest versions with the given pre-release policy.
+func WithPrereleasePolicy(ctx context.Context, policy PrereleasePolicy) context.Context {
+	return context.WithValue(ctx, prereleaseKey{}, policy)
+}
+
+// prereleasePolicy returns the pre-release policy of the context, auto by default.
+func prereleasePolicy(ctx context.Context) PrereleasePolicy {
+	if policy, ok := ctx.Value(prereleaseKey{}).(PrereleasePolicy); ok && len(policy) != 0 {
+		return policy
+	}
+
+	return PrereleaseAuto
+}
+
+// pickLatest returns the highest of the versions allowed by the policy, empty if none is allowed.
+func pickLatest(versions []string, policy PrereleasePolicy) string {
+	var release, prerelease string
+
+	for _, version := range versions {
+		if len(semver.Prerelease(version)) == 0 {
+			if semver.Compare(version, release) > 0 {
+				release = version
+			}
+		} else if semver.Compare(version, prerelease) > 0 {
+			prerelease = version
+		}
+	}
+
+	switch {
+	case policy == PrereleaseExclude || (policy == PrereleaseAuto && len(release) != 0):
+		return release
+	case semver.Compare(prerelease, release) > 0:
+		return prerelease
+	default:
+		return release
+	}
+}
+
+// getLatestVersion returns the latest version of the module, resolved from the versions listed by the
+// Go proxy (/@v/list) with the pre-release policy of the context. Versions retracted by the go.mod of
+// the latest version are dropped, unless every version is retracted (as the go command does).
+// If the proxy lists no versions (e.g. the module has only pseudo-versions), the @latest response is used.
+func getLatestVersion(ctx context.Context, pkg string) (string, error) {
+	list, err := listVersions(ctx, pkg)
+	if err != nil {
+		return "", err
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(pkg)
+
+	list = slices.DeleteFunc(list, func(version string) bool {
+		return !semver.IsValid(version) || module.CheckPathMajor(version, pathMajor) != nil
+	})
+
+	if len(list) == 0 {
+		return proxyLatestVersion(ctx, pkg)
+	}
+
+	policy := prereleasePolicy(ctx)
+
+	retracted, err := retractedVersions(ctx, pkg)
+	if err != nil {
+		slog.Debug("Failed to get retracted versions", "module", pkg, "error", err)
+	}
+
+	allowed := slices.DeleteFunc(slices.Clone(list), func(version string) bool { return isRetracted(version, retracted) })
+
+	latest := pickLatest(allowed, policy)
+	if len(latest) == 0 {
+		latest = pickLatest(list, policy)
+
+		if len(latest) != 0 {
+			slog.Warn("Every version is retracted, using the latest one", "module", pkg, "version", latest)
+		}
+	}
+
+	if len(latest) == 0 {
+		return "", fmt.Errorf("%w of %s with the %s pre-release policy", errNoRelease, pkg, policy)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPickLatest(t *testing.T) {
+	t.Parallel()
+
+	versions := []string{"v1.8.0", "v1.9.0", "v2.0.0-rc.1"}
+
+	tests := map[PrereleasePolicy]string{
+		PrereleaseAuto:    "v1.9.0",
+		PrereleaseInclude: "v2.0.0-rc.1",
+		PrereleaseExclude: "v1.9.0",
+	}
+
+	for policy, expected := range tests {
+		if got := pickLatest(versions, policy); got != expected {
+			t.Errorf("%s: expected %s, got %s", policy, expected, got)
+		}
+	}
+
+	prereleases := []string{"v0.1.0-alpha", "v0.1.0-beta"}
+
+	if got := pickLatest(prereleases, PrereleaseAuto); got != "v0.1.0-beta" {
+		t.Errorf("auto: expected the latest pre-release without releases, got %s", got)
+	}
+
+	if got := pickLatest(prereleases, PrereleaseExclude); got != "" {
+		t.Errorf("exclude: expected no version, got %s", got)
+	}
+}
+
+func TestParsePrereleasePolicy(t *testing.T) {
+	t.Parallel()
+
+	if policy, err := ParsePrereleasePolicy(""); err != nil || policy != PrereleaseAuto {
+		t.Errorf("expected auto, got %q (%v)", policy, err)
+	}
+
+	if _, err := ParsePrereleasePolicy("always"); !errors.Is(err, ErrInvalidPrereleasePolicy) {
+		t.Errorf("expected ErrInvalidPrereleasePolicy, got %v", err)
+	}
+}
+
+func newPrereleaseProxy(t *testing.T) {
+	t.Helper()
+
+	const k6 = "/example.com/k6"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case k6 + "/@v/list":
+			_, _ = fmt.Fprint(w, "v1.8.0\nv1.9.0\nv1.9.1\n")
+		case k6 + "/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v1.9.1"}`)
+		case k6 + "/@v/v1.9.1.mod":
+			_, _ = fmt.Fprint(w, "module example.com/k6\n\nretract [v1.9.1, v1.9.1] // published by mistake\n")
+		case k6 + "/v2/@v/list":
+			_, _ = fmt.Fprint(w, "v2.0.0-rc.1\n")
+		case k6 + "/v2/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v2.0.0-rc.1"}`)
+		case k6 + "/v2/@v/v2.0.0-rc.1.mod":
+			_, _ = fmt.Fprint(w, "module example.com/k6/v2\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv(envProxyCacheTTL, "0")
+}
+
+func TestGetLatestVersion_Retracted(t *testing.T) {
+	newPrereleaseProxy(t)
+
+	version, err := getLatestVersion(t.Context(), "example.com/k6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "v1.9.0" {
+		t.Errorf("expected v1.9.0, got %s", version)
+	}
+
+	_, err = getLatestVersion(WithPrereleasePolicy(t.Context(), PrereleaseExclude), "example.com/k6/v2")
+	if !errors.Is(err, errNoRelease) {
+		t.Errorf("expected errNoRelease, got %v", err)
+	}
+}
+
+func TestGetOverallLatestVersionFor_Prerelease(t *testing.T) {
+	newPrereleaseProxy(t)
+
+	tests := map[PrereleasePolicy]string{
+		PrereleaseAuto:    "example.com/k6@v1.9.0",
+		PrereleaseExclude: "example.com/k6@v1.9.0",
+		PrereleaseInclude: "example.com/k6/v2@v2.0.0-rc.1",
+	}
+
+	for policy, expected := range tests {
+		path, version, err := getOverallLatestVersionFor(WithPrereleasePolicy(t.Context(), policy), "example.com/k6")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", policy, err)
+
+			continue
+		}
+
+		if got := path + "@" + version; got != expected {
+			t.Errorf("%s: expected %s, got %s", policy, expected, got)
+		}
+	}
+}
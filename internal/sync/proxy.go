@@ -0,0 +1,442 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	defaultGoProxy = "https://proxy.golang.org,direct"
+	proxyDirect    = "direct"
+	proxyOff       = "off"
+	fileScheme     = "file://"
+	// goEnvOff is the GOENV value disabling the settings written by go env -w.
+	goEnvOff = "off"
+)
+
+var (
+	errProxyOff        = errors.New("module lookup disabled by GOPROXY=off")
+	errInvalidProxyURL = errors.New("invalid proxy URL")
+)
+
+// proxy is an entry of the GOPROXY list.
+type proxy struct {
+	// url is the proxy URL, or one of the direct and off keywords.
+	url string
+	// fallbackOnError is true if the next proxy is tried on any error (pipe separator),
+	// not only on 404 and 410 responses (comma separator).
+	fallbackOnError bool
+}
+
+// proxyList returns the proxies to use for the module, like the go command does:
+// GOPROXY is a list of proxy URLs separated by commas or pipes, and the modules matching
+// the GONOPROXY (by default GOPRIVATE) patterns are always fetched directly.
+func proxyList(modulePath string) []proxy {
+	noproxy := goEnv("GONOPROXY")
+	if len(noproxy) == 0 {
+		noproxy = goEnv("GOPRIVATE")
+	}
+
+	if len(noproxy) != 0 && module.MatchPrefixPatterns(noproxy, modulePath) {
+		return []proxy{{url: proxyDirect}}
+	}
+
+	return goProxies()
+}
+
+// goProxies returns the entries of the GOPROXY list.
+func goProxies() []proxy {
+	value := goEnv("GOPROXY")
+	if len(value) == 0 {
+		value = defaultGoProxy
+	}
+
+	var proxies []proxy
+
+	for len(value) != 0 {
+		entry, rest := value, ""
+		fallbackOnError := false
+
+		if idx := strings.IndexAny(value, ",|"); idx >= 0 {
+			entry, rest = value[:idx], value[idx+1:]
+			fallbackOnError = value[idx] == '|'
+		}
+
+		value = rest
+
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		proxies = append(proxies, proxy{url: strings.TrimSuffix(entry, "/"), fallbackOnError: fallbackOnError})
+
+		if entry == proxyOff {
+			break
+		}
+	}
+
+	return proxies
+}
+
+// goEnv returns the value of a Go environment variable. Like for the go command, a non-empty value
+// in the process environment takes precedence over the settings written by go env -w to the GOENV file,
+// which is not read if GOENV is off.
+func goEnv(key string) string {
+	if value := os.Getenv(key); len(value) != 0 { //nolint:forbidigo
+		return value
+	}
+
+	file := os.Getenv("GOENV") //nolint:forbidigo
+	if file == goEnvOff {
+		return ""
+	}
+
+	if len(file) == 0 {
+		dir, err := os.UserConfigDir() //nolint:forbidigo
+		if err != nil {
+			return ""
+		}
+
+		file = filepath.Join(dir, "go", "env")
+	}
+
+	data, err := os.ReadFile(file) //nolint:forbidigo,gosec
+	if err != nil {
+		return ""
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if name, value, found := strings.Cut(strings.TrimSpace(line), "="); found && name == key {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// proxyFetch fetches a path of the Go module proxy protocol (e.g. /go.k6.io/k6/@latest) and returns the response.
+// The proxies of the GOPROXY list are tried in order: the next one is tried after a 404 or 410 response,
+// or after any error if the proxies are separated by a pipe. Responses with other status codes are returned as-is;
+// callers are responsible for checking resp.StatusCode and closing resp.Body.
+func proxyFetch(ctx context.Context, path string) (*http.Response, error) {
+	modulePath, suffix, err := splitProxyPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		last    *http.Response
+		lastErr = fmt.Errorf("%w: %s", errProxyOff, modulePath)
+	)
+
+	for _, proxy := range proxyList(modulePath) {
+		if last != nil {
+			_, _ = io.Copy(io.Discard, last.Body)
+			_ = last.Body.Close()
+			last = nil
+		}
+
+		resp, err := proxyGet(ctx, proxy, modulePath, path, suffix)
+		if err != nil {
+			if !proxy.fallbackOnError {
+				return nil, err
+			}
+
+			slog.Debug("Go proxy failed, trying next", "proxy", proxy.url, "error", err)
+
+			lastErr = err
+
+			continue
+		}
+
+		if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusGone {
+			return resp, nil
+		}
+
+		slog.Debug("Module not found in Go proxy", "proxy", proxy.url, "path", path, "status", resp.StatusCode)
+
+		last, lastErr = resp, nil
+	}
+
+	if last != nil {
+		return last, nil
+	}
+
+	return nil, lastErr
+}
+
+func proxyGet(ctx context.Context, proxy proxy, modulePath, path, suffix string) (*http.Response, error) {
+	switch {
+	case proxy.url == proxyOff:
+		return nil, fmt.Errorf("%w: %s", errProxyOff, modulePath)
+	case proxy.url == proxyDirect:
+		return directGet(ctx, modulePath, suffix)
+	case strings.HasPrefix(proxy.url, fileScheme):
+		return fileProxyGet(proxy.url + path)
+	case strings.HasPrefix(proxy.url, "https://"), strings.HasPrefix(proxy.url, "http://"):
+		return httpProxyGet(ctx, proxy.url+path, path)
+	default:
+		return nil, fmt.Errorf("%w: %s", errInvalidProxyURL, proxy.url)
+	}
+}
+
+// splitProxyPath splits a proxy path into the module path and the protocol suffix (e.g. /@v/list).
+func splitProxyPath(path string) (string, string, error) {
+	idx := strings.Index(path, "/@")
+	if idx < 1 {
+		return "", "", fmt.Errorf("%w: %s", errInvalidProxyURL, path)
+	}
+
+	modulePath, err := module.UnescapePath(path[1:idx])
+	if err != nil {
+		return "", "", err
+	}
+
+	return modulePath, path[idx:], nil
+}
+
+// httpProxyGet fetches a URL from an HTTP Go module proxy.
+// It retries on network errors and 5xx responses (up to 3 attempts, with 1s/2s backoff).
+func httpProxyGet(ctx context.Context, fullURL, path string) (*http.Response, error) {
+	slog.Debug("Go proxy request", "url", fullURL)
+
+	const maxAttempts = 3
+
+	var lastErr error
+
+	for attempt := range maxAttempts {
+		if attempt > 0 {
+			delay := time.Duration(1<<(attempt-1)) * time.Second // 1s, 2s
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+		if err != nil {
+			return nil, err // malformed request, no point retrying
+		}
+
+		resp, err := doAuthenticated(ctx, req)
+		if err != nil {
+			slog.Debug("Go proxy request failed", "url", fullURL, "attempt", attempt+1, "error", err)
+			lastErr = err
+
+			continue
+		}
+
+		slog.Debug("Go proxy response", "url", fullURL, "status", resp.StatusCode) //nolint:gosec
+
+		// Retry on server-side errors; return everything else to the caller for status checking.
+		if resp.StatusCode >= 500 {
+			slog.Debug("Go proxy server error, will retry", //nolint:gosec
+				"url", fullURL, "attempt", attempt+1, "status", resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			lastErr = fmt.Errorf("%w: %s, url: %s", errHTTP, resp.Status, path)
+
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// doAuthenticated sends the request with the credentials of the proxy. After a 4xx response
+// (except 404, which is the expected answer of version probes), the request is sent once more
+// if the GOAUTH commands provide new credentials for the URL.
+func doAuthenticated(ctx context.Context, req *http.Request) (*http.Response, error) {
+	addCredentials(ctx, req)
+
+	resp, err := http.DefaultClient.Do(req) //nolint:gosec
+	if err != nil || resp.StatusCode < 400 || resp.StatusCode >= 500 || resp.StatusCode == http.StatusNotFound {
+		return resp, err
+	}
+
+	if !retryCredentials(ctx, req, resp) {
+		return resp, nil
+	}
+
+	slog.Debug("Retrying Go proxy request with new credentials", "url", req.URL.String(), "status", resp.StatusCode)
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return http.DefaultClient.Do(req) //nolint:gosec
+}
+
+// fileProxyGet serves a request of a file:// Go proxy (e.g. the directory of an offline build)
+// from the local filesystem. A missing file is reported as 404 Not Found, like an HTTP proxy would.
+func fileProxyGet(fullURL string) (*http.Response, error) {
+	slog.Debug("Go proxy request", "url", fullURL)
+
+	u, err := url.Parse(fullURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// on windows, the path of file:///C:/dir is /C:/dir
+	path := u.Path
+	if len(path) > 1 && len(filepath.VolumeName(path[1:])) != 0 {
+		path = path[1:]
+	}
+
+	file, err := os.Open(filepath.FromSlash(path)) //nolint:forbidigo
+	if errors.Is(err, fs.ErrNotExist) {
+		return newResponse(http.StatusNotFound, nil), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: file}, nil
+}
+
+// goListModule is the output of go list -m -json.
+type goListModule struct {
+	Version  string     `json:"Version"`
+	Versions []string   `json:"Versions"`
+	Time     *time.Time `json:"Time"`
+	GoMod    string     `json:"GoMod"`
+}
+
+// directGet serves a request of the Go module proxy protocol directly from the version control
+// repository of the module, using go list -m, so the same VCS authentication (ssh keys,
+// git credential helpers) as for go build is used.
+func directGet(ctx context.Context, modulePath, suffix string) (*http.Response, error) {
+	var args []string
+
+	ext := filepath.Ext(suffix)
+
+	switch {
+	case suffix == "/@v/list":
+		args = []string{"-versions", modulePath}
+	case suffix == "/@latest":
+		args = []string{modulePath + "@latest"}
+	case strings.HasPrefix(suffix, "/@v/") && (ext == ".info" || ext == ".mod"):
+		version, err := module.UnescapeVersion(strings.TrimSuffix(strings.TrimPrefix(suffix, "/@v/"), ext))
+		if err != nil {
+			return nil, err
+		}
+
+		args = []string{modulePath + "@" + version}
+	default:
+		return newResponse(http.StatusNotFound, nil), nil
+	}
+
+	out, err := goListDirect(ctx, args...)
+	if err != nil {
+		if isNotFound(err) {
+			slog.Debug("Module not found in version control", "module", modulePath, "error", err)
+
+			return newResponse(http.StatusNotFound, nil), nil
+		}
+
+		return nil, err
+	}
+
+	var mod goListModule
+
+	if err := json.Unmarshal(out, &mod); err != nil {
+		return nil, err
+	}
+
+	return directResponse(suffix, &mod)
+}
+
+// directResponse converts the output of go list -m -json to the response of the proxy protocol request.
+func directResponse(suffix string, mod *goListModule) (*http.Response, error) {
+	switch {
+	case suffix == "/@v/list":
+		return newResponse(http.StatusOK, []byte(strings.Join(mod.Versions, "\n"))), nil
+	case strings.HasSuffix(suffix, ".mod"):
+		data, err := os.ReadFile(mod.GoMod) //nolint:forbidigo
+		if err != nil {
+			return nil, err
+		}
+
+		return newResponse(http.StatusOK, data), nil
+	default:
+		data, err := json.Marshal(versionInfoOf(mod))
+		if err != nil {
+			return nil, err
+		}
+
+		return newResponse(http.StatusOK, data), nil
+	}
+}
+
+func versionInfoOf(mod *goListModule) versionInfo {
+	info := versionInfo{Version: mod.Version}
+
+	if mod.Time != nil {
+		info.Time = mod.Time.Format(time.RFC3339)
+	}
+
+	return info
+}
+
+// goListDirect runs go list -m -json outside of any module, bypassing the Go proxies.
+func goListDirect(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "go", append([]string{"list", "-m", "-json"}, args...)...)
+
+	cmd.Dir = os.TempDir()                                                           //nolint:forbidigo
+	cmd.Env = append(os.Environ(), "GOPROXY="+proxyDirect, "GOWORK=off", "GOFLAGS=") //nolint:forbidigo
+
+	var stderr bytes.Buffer
+
+	cmd.Stderr = &stderr
+
+	slog.Debug("Go direct request", "args", args)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// isNotFound reports whether the go command failed because the module or the version does not exist.
+func isNotFound(err error) bool {
+	msg := err.Error()
+
+	for _, marker := range []string{"not found", "unknown revision", "no matching versions", "404 Not Found"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newResponse(status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
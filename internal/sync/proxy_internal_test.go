@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyList(t *testing.T) {
+	tests := []struct {
+		goproxy  string
+		private  string
+		expected []proxy
+	}{
+		{"", "", []proxy{{url: "https://goenv"}}},
+		{"https://a/,https://b|direct", "", []proxy{{url: "https://a"}, {url: "https://b", fallbackOnError: true}, {url: "direct"}}},
+		{"off,https://a", "", []proxy{{url: "off"}}},
+		{"https://a", "example.com/private", []proxy{{url: "direct"}}},
+		{"https://a", "example.com/other", []proxy{{url: "https://a"}}},
+	}
+
+	// settings written by go env -w are ignored unless the variable is not set
+	goenv := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(goenv, []byte("GOPROXY=https://goenv\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOENV", goenv)
+
+	for _, test := range tests {
+		t.Setenv("GOPROXY", test.goproxy)
+		t.Setenv("GONOPROXY", "")
+		t.Setenv("GOPRIVATE", test.private)
+
+		if got := proxyList("example.com/private/xk6-foo"); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("GOPROXY=%q GOPRIVATE=%q: expected %v, got %v", test.goproxy, test.private, test.expected, got)
+		}
+	}
+
+	// like the go command, the GOENV file is not read if GOENV is off
+	t.Setenv("GOENV", "off")
+	t.Setenv("GOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+
+	expected := []proxy{{url: "https://proxy.golang.org"}, {url: "direct"}}
+	if got := proxyList("example.com/xk6-foo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GOENV=off: expected %v, got %v", expected, got)
+	}
+}
+
+func newLatestProxy(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+		_, _ = fmt.Fprint(w, `{"version":"v1.2.3"}`)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+func TestGoProxyGet_FallbackOnNotFound(t *testing.T) {
+	first, _ := newLatestProxy(t, http.StatusNotFound)
+	second, _ := newLatestProxy(t, http.StatusOK)
+
+	t.Setenv("GOPROXY", first.URL+","+second.URL)
+
+	version, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "v1.2.3" {
+		t.Errorf("expected v1.2.3 from the second proxy, got %s", version)
+	}
+}
+
+func TestGoProxyGet_FallbackOnError(t *testing.T) {
+	srv, hits := newLatestProxy(t, http.StatusOK)
+
+	// comma: only 404 and 410 fall back
+	t.Setenv("GOPROXY", "ftp://invalid,"+srv.URL)
+
+	if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); !errors.Is(err, errInvalidProxyURL) {
+		t.Errorf("expected errInvalidProxyURL, got %v", err)
+	}
+
+	// pipe: any error falls back
+	t.Setenv("GOPROXY", "ftp://invalid|"+srv.URL)
+
+	if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("expected 1 request to the second proxy, got %d", hits.Load())
+	}
+}
+
+func TestGoProxyGet_Off(t *testing.T) {
+	t.Setenv("GOPROXY", "off")
+
+	if _, err := getLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); !errors.Is(err, errProxyOff) {
+		t.Errorf("expected errProxyOff, got %v", err)
+	}
+}
+
+func TestGoProxyGet_NoProxy(t *testing.T) {
+	srv, hits := newLatestProxy(t, http.StatusOK)
+
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("GONOPROXY", "example.invalid")
+
+	// private modules are fetched directly from version control, never from the proxy
+	_, _ = getLatestVersion(t.Context(), "example.invalid/xk6-private")
+
+	if hits.Load() != 0 {
+		t.Errorf("expected no proxy request for a private module, got %d", hits.Load())
+	}
+}
+
+func TestDirectResponse(t *testing.T) {
+	t.Parallel()
+
+	gomod := filepath.Join(t.TempDir(), "go.mod")
+	if err := os.WriteFile(gomod, []byte("module example.com/foo\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2025, 5, 6, 10, 39, 35, 0, time.UTC)
+	mod := &goListModule{Version: "v1.0.0", Versions: []string{"v0.9.0", "v1.0.0"}, Time: &modTime, GoMod: gomod}
+
+	tests := map[string]string{
+		"/@v/list":        "v0.9.0\nv1.0.0",
+		"/@latest":        `{"Version":"v1.0.0","Time":"2025-05-06T10:39:35Z"}`,
+		"/@v/v1.0.0.info": `{"Version":"v1.0.0","Time":"2025-05-06T10:39:35Z"}`,
+		"/@v/v1.0.0.mod":  "module example.com/foo\n",
+	}
+
+	for suffix, expected := range tests {
+		resp, err := directResponse(suffix, mod)
+		if err != nil {
+			t.Fatalf("%s: %v", suffix, err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK || string(body) != expected {
+			t.Errorf("%s: unexpected response %d %q", suffix, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestGoProxyGet_File(t *testing.T) {
+	dir := t.TempDir()
+
+	versions := filepath.Join(dir, "go.k6.io", "k6", "@v")
+	if err := os.MkdirAll(versions, 0o750); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(versions, "list"), []byte("v1.0.0\nv1.1.0\n"), 0o600); err != nil { //nolint:forbidigo
+		t.Fatal(err)
+	}
+
+	path := filepath.ToSlash(dir)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // windows drive letter
+	}
+
+	t.Setenv("GOPROXY", "file://"+path)
+
+	versionList, err := listVersions(t.Context(), "go.k6.io/k6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(versionList, []string{"v1.0.0", "v1.1.0"}) {
+		t.Errorf("unexpected versions: %v", versionList)
+	}
+
+	// a missing file is reported as 404, like an HTTP proxy would
+	_, err = getLatestVersion(t.Context(), "go.k6.io/k6/v2")
+	if !errors.Is(err, errHTTP) {
+		t.Errorf("expected errHTTP, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"go.k6.io/xk6/internal/cache"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// envProxyCacheTTL is the time to live of the cached Go proxy responses that can change
+	// (e.g. @latest or not found responses). Zero disables their cache, and off disables the whole cache.
+	envProxyCacheTTL     = "XK6_PROXY_CACHE_TTL"
+	defaultProxyCacheTTL = 5 * time.Minute
+	proxyCacheOff        = "off"
+
+	proxyCacheDir = "proxy"
+	cacheDirPerm  = 0o750
+)
+
+// cachedResponse is a Go proxy response kept in the cache.
+type cachedResponse struct {
+	Status int       `json:"status"`
+	Body   []byte    `json:"body"`
+	Time   time.Time `json:"time"`
+}
+
+func (c *cachedResponse) response() *http.Response {
+	return newResponse(c.Status, c.Body)
+}
+
+// proxyCall is an in-flight Go proxy request, shared by the concurrent callers requesting the same path.
+type proxyCall struct {
+	wg   gosync.WaitGroup
+	resp *cachedResponse
+	err  error
+}
+
+var proxyCache = struct { //nolint:gochecknoglobals
+	mu    gosync.Mutex
+	calls map[string]*proxyCall
+	done  map[string]*cachedResponse
+}{
+	calls: make(map[string]*proxyCall),
+	done:  make(map[string]*cachedResponse),
+}
+
+// goProxyGet fetches a path of the Go module proxy protocol (e.g. /go.k6.io/k6/@latest) and returns the response.
+// Concurrent requests of the same path are sent only once. The .info and .mod responses of immutable versions
+// are cached in the process and on disk, so they are not requested again by the next commands. The responses
+// that can change (@latest, @v/list, not found responses) are cached until XK6_PROXY_CACHE_TTL expires.
+// Callers are responsible for checking resp.StatusCode and closing resp.Body.
+func goProxyGet(ctx context.Context, path string) (*http.Response, error) {
+	ttl, enabled := proxyCacheTTL()
+	key := proxyCacheKey(path)
+
+	proxyCache.mu.Lock()
+
+	if cached, found := proxyCache.done[key]; found {
+		proxyCache.mu.Unlock()
+
+		return cached.response(), nil
+	}
+
+	if call, found := proxyCache.calls[key]; found {
+		proxyCache.mu.Unlock()
+		call.wg.Wait()
+
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		return call.resp.response(), nil
+	}
+
+	call := new(proxyCall)
+	call.wg.Add(1)
+	proxyCache.calls[key] = call
+
+	proxyCache.mu.Unlock()
+
+	call.resp, call.err = cachedProxyFetch(ctx, path, key, ttl, enabled)
+
+	proxyCache.mu.Lock()
+
+	delete(proxyCache.calls, key)
+
+	if call.err == nil && enabled && call.resp.cacheable(path, ttl) {
+		proxyCache.done[key] = call.resp
+	}
+
+	proxyCache.mu.Unlock()
+
+	call.wg.Done()
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return call.resp.response(), nil
+}
+
+// cachedProxyFetch returns the response from the disk cache if it is still fresh, otherwise
+// it fetches it from the Go proxies. The cacheable responses are written to the disk cache,
+// unless the request was sent with credentials: the cache key does not contain them.
+func cachedProxyFetch(ctx context.Context, path, key string, ttl time.Duration, enabled bool) (*cachedResponse, error) {
+	var file string
+
+	if enabled && isDiskCacheable(path) {
+		file = proxyCacheFile(key)
+	}
+
+	if cached, ok := readCachedResponse(file); ok && (isImmutable(path, cached) || time.Since(cached.Time) < ttl) {
+		slog.Debug("Go proxy response from cache", "path", path, "status", cached.Status)
+
+		return cached, nil
+	}
+
+	resp, err := proxyFetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &cachedResponse{Status: resp.StatusCode, Body: body, Time: time.Now().UTC()}
+
+	if cached.cacheable(path, ttl) && !sentCredentials(resp) {
+		writeCachedResponse(file, cached)
+	}
+
+	return cached, nil
+}
+
+// cacheable reports whether the response can be cached: immutable responses always, other successful
+// and not found responses only with a time to live. Authentication failures and the like are never cached.
+func (c *cachedResponse) cacheable(path string, ttl time.Duration) bool {
+	switch c.Status {
+	case http.StatusOK, http.StatusNotFound, http.StatusGone:
+		return ttl > 0 || isImmutable(path, c)
+	default:
+		return false
+	}
+}
+
+// sentCredentials reports whether the request of the response was sent with credentials: the user info of
+// the proxy URL, or the headers set by addCredentials (the requests to the Go proxies have no other headers).
+// The original request is checked, the requests of redirects have their own headers.
+func sentCredentials(resp *http.Response) bool {
+	req := resp.Request
+	if req == nil {
+		return false
+	}
+
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+
+	return req.URL.User != nil || len(req.Header) != 0
+}
+
+// proxyCacheTTL returns the time to live of the mutable responses from XK6_PROXY_CACHE_TTL (e.g. 30m),
+// defaultProxyCacheTTL if it is not set, and false if the cache is disabled.
+func proxyCacheTTL() (time.Duration, bool) {
+	value := os.Getenv(envProxyCacheTTL) //nolint:forbidigo
+	if len(value) == 0 {
+		return defaultProxyCacheTTL, true
+	}
+
+	if value == proxyCacheOff {
+		return 0, false
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Debug("Invalid proxy cache TTL, using the default", "value", value, "default", defaultProxyCacheTTL, "error", err)
+
+		return defaultProxyCacheTTL, true
+	}
+
+	return ttl, true
+}
+
+// proxyCacheKey returns the cache key of the path. The proxy settings are part of the key,
+// so that a response is not served for a different set of proxies.
+func proxyCacheKey(path string) string {
+	return strings.Join([]string{goEnv("GOPROXY"), goEnv("GONOPROXY"), goEnv("GOPRIVATE"), path}, "\n")
+}
+
+func isDiskCacheable(path string) bool {
+	return strings.HasSuffix(path, "/@latest") || strings.HasSuffix(path, "/@v/list") ||
+		strings.HasSuffix(path, ".info") || strings.HasSuffix(path, ".mod")
+}
+
+// isImmutable reports whether the response can never change: the .info and .mod of a semantic version
+// (including pseudo-versions) are immutable, while @latest, @v/list, the .info of branch names and not found
+// responses may change at any time.
+func isImmutable(path string, cached *cachedResponse) bool {
+	if cached.Status != http.StatusOK {
+		return false
+	}
+
+	_, suffix, err := splitProxyPath(path)
+	if err != nil || !strings.HasPrefix(suffix, "/@v/") {
+		return false
+	}
+
+	escaped := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(suffix, "/@v/"), ".info"), ".mod")
+
+	version, err := module.UnescapeVersion(escaped)
+	if err != nil {
+		return false
+	}
+
+	return semver.IsValid(version) && semver.Canonical(version) == strings.TrimSuffix(version, "+incompatible")
+}
+
+func proxyCacheFile(key string) string {
+	dir, err := cache.Dir()
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(dir, proxyCacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+func readCachedResponse(file string) (*cachedResponse, bool) {
+	if len(file) == 0 {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(file) //nolint:forbidigo,gosec
+	if err != nil {
+		return nil, false
+	}
+
+	cached := new(cachedResponse)
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, false
+	}
+
+	return cached, true
+}
+
+// writeCachedResponse writes the response to the disk cache. Failures are logged only, as the cache is an optimization.
+func writeCachedResponse(file string, cached *cachedResponse) {
+	if len(file) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(file), cacheDirPerm) //nolint:forbidigo
+	}
+
+	if err == nil {
+		err = writeFileAtomic(file, data)
+	}
+
+	if err != nil {
+		slog.Debug("Failed to cache Go proxy response", "file", file, "error", err)
+	}
+}
+
+// writeFileAtomic writes a temporary file and renames it, so concurrent commands never read a partial file.
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*") //nolint:forbidigo
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name()) //nolint:forbidigo
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file) //nolint:forbidigo
+}
+
+// CleanProxyCache removes the cached Go proxy responses from the disk cache.
+func CleanProxyCache() error {
+	dir, err := cache.Dir()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(dir, proxyCacheDir)) //nolint:forbidigo
+}
@@ -0,0 +1,199 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// the tests use many short-lived proxies, responses must not be served from the cache
+	_ = os.Setenv(envProxyCacheTTL, proxyCacheOff) //nolint:forbidigo
+	// the modules served by the test proxies are not in the public checksum database
+	_ = os.Setenv("GOSUMDB", goSumDBOff) //nolint:forbidigo
+
+	os.Exit(m.Run()) //nolint:forbidigo
+}
+
+func newCountingProxy(t *testing.T) *atomic.Int32 {
+	t.Helper()
+
+	return newCountingServer(t, httptest.NewServer)
+}
+
+// newCountingServer is newCountingProxy with the given server constructor (e.g. httptest.NewTLSServer).
+func newCountingServer(t *testing.T, newServer func(http.Handler) *httptest.Server) *atomic.Int32 {
+	t.Helper()
+
+	var hits atomic.Int32
+
+	srv := newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+
+		switch r.URL.Path {
+		case "/github.com/grafana/xk6-foo/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v1.2.3"}`)
+		case "/github.com/grafana/xk6-foo/@v/v1.2.3.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-foo\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("XK6_CACHE_DIR", t.TempDir())
+
+	useServerClient(t, srv)
+
+	return &hits
+}
+
+func TestGoProxyGet_DiskCache(t *testing.T) {
+	hits := newCountingProxy(t)
+
+	t.Setenv(envProxyCacheTTL, "1h")
+
+	for range 2 {
+		if _, err := getModule(t.Context(), "github.com/grafana/xk6-foo", "v1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo/v2"); err == nil {
+			t.Fatal("expected not found error")
+		}
+
+		// a new command does not share the in-process cache
+		proxyCache.mu.Lock()
+		clear(proxyCache.done)
+		proxyCache.mu.Unlock()
+	}
+
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 proxy requests, got %d", hits.Load())
+	}
+
+	// the not found response expires, the immutable .mod does not
+	files, _ := filepath.Glob(filepath.Join(os.Getenv("XK6_CACHE_DIR"), proxyCacheDir, "*.json")) //nolint:forbidigo
+	for _, file := range files {
+		cached, _ := readCachedResponse(file)
+		cached.Time = cached.Time.Add(-2 * time.Hour)
+
+		writeCachedResponse(file, cached)
+	}
+
+	if _, err := getModule(t.Context(), "github.com/grafana/xk6-foo", "v1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo/v2")
+
+	if hits.Load() != 3 {
+		t.Errorf("expected only the expired response to be requested again, got %d requests", hits.Load())
+	}
+}
+
+func TestGoProxyGet_DefaultCache(t *testing.T) {
+	hits := newCountingProxy(t)
+
+	t.Setenv(envProxyCacheTTL, "")
+
+	for range 2 {
+		if _, err := getModule(t.Context(), "github.com/grafana/xk6-foo", "v1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		// a new command does not share the in-process cache
+		proxyCache.mu.Lock()
+		clear(proxyCache.done)
+		proxyCache.mu.Unlock()
+	}
+
+	// the @latest response is cached for a short time by default
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 proxy requests, got %d", hits.Load())
+	}
+
+	t.Setenv(envProxyCacheTTL, "0")
+
+	if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// without time to live, only the immutable .mod is cached
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 proxy requests, got %d", hits.Load())
+	}
+}
+
+func TestGoProxyGet_Credentials(t *testing.T) {
+	hits := newCountingServer(t, httptest.NewTLSServer)
+
+	proxy, err := url.Parse(os.Getenv("GOPROXY")) //nolint:forbidigo
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(envProxyTokens, proxy.Host+"=secret")
+	t.Setenv(envProxyCacheTTL, "1h")
+
+	for range 2 {
+		if _, err := getModule(t.Context(), "github.com/grafana/xk6-foo", "v1.2.3"); err != nil {
+			t.Fatal(err)
+		}
+
+		proxyCache.mu.Lock()
+		clear(proxyCache.done)
+		proxyCache.mu.Unlock()
+	}
+
+	// the responses to requests with credentials are not written to the disk cache
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 proxy requests, got %d", hits.Load())
+	}
+}
+
+func TestGoProxyGet_Dedup(t *testing.T) {
+	hits := newCountingProxy(t)
+
+	t.Setenv(envProxyCacheTTL, "1h")
+
+	for range 3 {
+		if _, err := proxyLatestVersion(t.Context(), "github.com/grafana/xk6-foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("expected 1 proxy request, got %d", hits.Load())
+	}
+}
+
+func TestProbeMajors(t *testing.T) {
+	t.Parallel()
+
+	existing := map[string]bool{"m/v2": true, "m/v4": true, "m/v5": true, "m/v9": true}
+
+	probe := func(_ context.Context, path string) (string, bool) { return path, existing[path] }
+
+	found := probeMajors(t.Context(), "m", 2, probe, nil)
+	if len(found) != 3 || found[2].path != "m/v5" {
+		t.Errorf("expected v2, v4 and v5, got %v", found)
+	}
+
+	found = probeMajors(t.Context(), "m", 2, probe, func(path string) bool { return path == "m/v4" })
+	if len(found) != 2 || found[1].path != "m/v4" {
+		t.Errorf("expected to stop at v4, got %v", found)
+	}
+}
@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	gosync "sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// versionInfoConcurrency is the number of .info requests sent concurrently.
+const versionInfoConcurrency = 8
+
+// PublishedVersion represents a published version of a module.
+type PublishedVersion struct {
+	// Version is the semantic version.
+	Version string `json:"version"`
+	// Time is the publish time of the version, zero if unknown.
+	Time time.Time `json:"time,omitzero"`
+	// Prerelease is true for pre-release versions (e.g. v2.0.0-rc.1).
+	Prerelease bool `json:"prerelease,omitempty"`
+	// Retracted is true if the version is retracted by the latest version of the module.
+	Retracted bool `json:"retracted,omitempty"`
+}
+
+// MajorVersions represents the published versions of a major version module path.
+type MajorVersions struct {
+	// Module is the module path of the major version (e.g. go.k6.io/k6/v2).
+	Module string `json:"module"`
+	// Versions is the list of published versions, newest first.
+	Versions []*PublishedVersion `json:"versions"`
+}
+
+// PublishedVersions returns the published versions of the module across all major versions, grouped by
+// major version, newest major version first. If the module path has a major version suffix (e.g. /v2),
+// only that major version is listed. If modulePath is empty, the versions of k6 are listed.
+// The publish times are taken from the .info endpoint of the Go proxy.
+func PublishedVersions(ctx context.Context, modulePath string) ([]*MajorVersions, error) {
+	if len(modulePath) == 0 {
+		modulePath = k6BaseModule
+	}
+
+	paths := majorModulePaths(ctx, modulePath)
+
+	majors := make([]*MajorVersions, 0, len(paths))
+
+	for _, path := range slices.Backward(paths) {
+		versions, err := publishedVersions(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(versions) != 0 {
+			majors = append(majors, &MajorVersions{Module: path, Versions: versions})
+		}
+	}
+
+	return majors, nil
+}
+
+// publishedVersions returns the published versions of a module path, newest first.
+func publishedVersions(ctx context.Context, modulePath string) ([]*PublishedVersion, error) {
+	list, err := listVersions(ctx, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+
+	list = slices.DeleteFunc(list, func(version string) bool {
+		return !semver.IsValid(version) || module.CheckPathMajor(version, pathMajor) != nil
+	})
+
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	semver.Sort(list)
+	slices.Reverse(list)
+
+	retracted, err := retractedVersions(ctx, modulePath)
+	if err != nil {
+		slog.Debug("Failed to get retracted versions", "module", modulePath, "error", err)
+	}
+
+	versions := make([]*PublishedVersion, len(list))
+
+	var wg gosync.WaitGroup
+
+	limit := make(chan struct{}, versionInfoConcurrency)
+
+	for idx, version := range list {
+		versions[idx] = &PublishedVersion{
+			Version:    version,
+			Prerelease: len(semver.Prerelease(version)) != 0,
+			Retracted:  isRetracted(version, retracted),
+		}
+
+		wg.Go(func() {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			versions[idx].Time = publishTime(ctx, modulePath, version)
+		})
+	}
+
+	wg.Wait()
+
+	return versions, nil
+}
+
+// publishTime returns the publish time of the module version, zero if it is not available.
+func publishTime(ctx context.Context, modulePath, version string) time.Time {
+	info, err := getVersionInfo(ctx, modulePath, version)
+	if err != nil {
+		slog.Debug("Failed to get version info", "module", modulePath, "version", version, "error", err)
+
+		return time.Time{}
+	}
+
+	published, err := time.Parse(time.RFC3339, info.Time)
+	if err != nil {
+		slog.Debug("Invalid version time", "module", modulePath, "version", version, "time", info.Time)
+
+		return time.Time{}
+	}
+
+	return published
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishedVersions(t *testing.T) {
+	newVersionsProxy(t)
+
+	majors, err := PublishedVersions(t.Context(), "github.com/grafana/xk6-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(majors) != 2 || majors[0].Module != "github.com/grafana/xk6-foo/v2" || majors[1].Module != "github.com/grafana/xk6-foo" {
+		t.Fatalf("unexpected majors: %+v", majors)
+	}
+
+	latest := majors[0].Versions[0]
+	if latest.Version != "v2.1.0" || !latest.Time.Equal(time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected latest version: %+v", latest)
+	}
+
+	expected := []PublishedVersion{
+		{Version: "v1.4.0-rc.1", Prerelease: true},
+		{Version: "v1.3.0"},
+		{Version: "v1.2.4", Retracted: true},
+		{Version: "v1.2.3"},
+		{Version: "v1.2.0"},
+	}
+
+	if len(majors[1].Versions) != len(expected) {
+		t.Fatalf("expected %d versions, got %d", len(expected), len(majors[1].Versions))
+	}
+
+	for idx, version := range majors[1].Versions {
+		if *version != expected[idx] {
+			t.Errorf("expected %+v, got %+v", expected[idx], *version)
+		}
+	}
+}
+
+func TestPublishedVersions_MajorPath(t *testing.T) {
+	newVersionsProxy(t)
+
+	majors, err := PublishedVersions(t.Context(), "github.com/grafana/xk6-foo/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(majors) != 1 || len(majors[0].Versions) != 2 {
+		t.Fatalf("unexpected majors: %+v", majors)
+	}
+}
@@ -0,0 +1,329 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+
+	"go.k6.io/xk6/internal/cache"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const (
+	defaultGoSumDB = "sum.golang.org"
+	goSumDBChina   = "sum.golang.google.cn"
+	goSumDBOff     = "off"
+	sumdbCacheDir  = "sumdb"
+	sumdbKeyConfig = "key"
+)
+
+// knownGoSumDB contains the verifier keys of the well-known checksum databases, like in the go command.
+var knownGoSumDB = map[string]string{ //nolint:gochecknoglobals
+	defaultGoSumDB: "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8",
+}
+
+var (
+	errChecksumMismatch = errors.New("checksum mismatch")
+	errInvalidGoSumDB   = errors.New("invalid GOSUMDB")
+)
+
+var (
+	// sumdbMu guards the checksum database configuration files (the latest signed tree).
+	sumdbMu gosync.Mutex //nolint:gochecknoglobals
+	// sumdbBases contains the URL used to access each checksum database, see sumdbBase.
+	sumdbBases = make(map[string]string) //nolint:gochecknoglobals
+)
+
+// verifyGoMod verifies the go.mod file of a module version, downloaded from a Go proxy, against the
+// checksum database, like the go command does. A proxy can not steer the version selection by serving
+// altered go.mod files this way. The verification is skipped for the modules matching GONOSUMDB
+// (by default GOPRIVATE), or if it is disabled by GOSUMDB=off or GOFLAGS=-insecure.
+func verifyGoMod(ctx context.Context, modulePath, version string, data []byte) error {
+	if !sumdbEnabled(modulePath) {
+		return nil
+	}
+
+	lines, name, err := sumdbLookup(ctx, modulePath, version+"/go.mod")
+	if err != nil {
+		return err
+	}
+
+	hash, err := goModHash(data)
+	if err != nil {
+		return err
+	}
+
+	prefix := modulePath + " " + version + "/go.mod "
+
+	for _, line := range lines {
+		if line == prefix+hash {
+			slog.Debug("go.mod verified", "module", modulePath, "version", version, "sumdb", name)
+
+			return nil
+		}
+	}
+
+	want := "(none)"
+	if len(lines) != 0 {
+		want = strings.TrimPrefix(lines[0], prefix)
+	}
+
+	return fmt.Errorf("%w: verifying %s@%s/go.mod\n\tdownloaded: %s\n\t%s: %s\n\n"+
+		"The go.mod file served by the Go proxy does not match the checksum database.\n"+
+		"The proxy may be compromised or misconfigured.",
+		errChecksumMismatch, modulePath, version, hash, name, want)
+}
+
+// sumdbLookup returns the go.sum lines of the module version (or version/go.mod) from the checksum database,
+// and the name of the database.
+func sumdbLookup(ctx context.Context, modulePath, version string) ([]string, string, error) {
+	name, key, direct, err := parseGoSumDB(goEnv("GOSUMDB"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ops := &sumdbOps{
+		ctx:  ctx,
+		key:  key,
+		base: sumdbBase(ctx, name, direct),
+		dir:  filepath.Join(dir, sumdbCacheDir),
+	}
+
+	lines, err := sumdb.NewClient(ops).Lookup(modulePath, version)
+	if err != nil {
+		return nil, "", fmt.Errorf("verifying %s@%s: %w", modulePath, version, err)
+	}
+
+	return lines, name, nil
+}
+
+// sumdbEnabled reports whether the go.mod files of the module are verified against the checksum database.
+func sumdbEnabled(modulePath string) bool {
+	if goEnv("GOSUMDB") == goSumDBOff {
+		return false
+	}
+
+	for _, flag := range strings.Fields(goEnv("GOFLAGS")) {
+		if name, value, _ := strings.Cut(strings.TrimLeft(flag, "-"), "="); name == "insecure" && value != "false" {
+			return false
+		}
+	}
+
+	nosumdb := goEnv("GONOSUMDB")
+	if len(nosumdb) == 0 {
+		nosumdb = goEnv("GOPRIVATE")
+	}
+
+	return len(nosumdb) == 0 || !module.MatchPrefixPatterns(nosumdb, modulePath)
+}
+
+// parseGoSumDB parses the GOSUMDB setting: the name of a well-known checksum database, or a verifier key,
+// optionally followed by the URL of the database. It returns the name, the verifier key and the URL.
+func parseGoSumDB(value string) (string, string, string, error) {
+	if len(value) == 0 {
+		value = defaultGoSumDB
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) > 2 { //nolint:mnd
+		return "", "", "", fmt.Errorf("%w: %q", errInvalidGoSumDB, value)
+	}
+
+	key := fields[0]
+
+	var direct string
+
+	if len(fields) == 2 { //nolint:mnd
+		direct = strings.TrimSuffix(fields[1], "/")
+	}
+
+	if key == goSumDBChina {
+		key, direct = knownGoSumDB[defaultGoSumDB], "https://"+goSumDBChina
+	} else if known, found := knownGoSumDB[key]; found {
+		key = known
+	}
+
+	verifier, err := note.NewVerifier(key)
+	if err != nil {
+		return "", "", "", fmt.Errorf("%w: %q: %w", errInvalidGoSumDB, value, err)
+	}
+
+	name := verifier.Name()
+
+	if len(direct) == 0 {
+		direct = "https://" + name
+	}
+
+	return name, key, direct, nil
+}
+
+// sumdbBase returns the URL used to access the checksum database. Like the go command does, the database
+// is accessed through the first Go proxy of the GOPROXY list supporting it, or directly otherwise.
+func sumdbBase(ctx context.Context, name, direct string) string {
+	cacheKey := goEnv("GOPROXY") + "\n" + name + "\n" + direct
+
+	sumdbMu.Lock()
+	base, found := sumdbBases[cacheKey]
+	sumdbMu.Unlock()
+
+	if found {
+		return base
+	}
+
+	base = direct
+
+	for _, proxy := range goProxies() {
+		if proxy.url == proxyDirect || proxy.url == proxyOff {
+			break
+		}
+
+		proxied := proxy.url + "/sumdb/" + name
+
+		resp, err := sumdbGet(ctx, proxied, "/supported")
+		if err != nil {
+			continue
+		}
+
+		_ = resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			base = proxied
+
+			break
+		}
+	}
+
+	slog.Debug("Checksum database", "name", name, "url", base)
+
+	sumdbMu.Lock()
+	sumdbBases[cacheKey] = base
+	sumdbMu.Unlock()
+
+	return base
+}
+
+func sumdbGet(ctx context.Context, base, path string) (*http.Response, error) {
+	if strings.HasPrefix(base, fileScheme) {
+		return fileProxyGet(base + path)
+	}
+
+	return httpProxyGet(ctx, base+path, path)
+}
+
+// goModHash returns the go.sum hash of a go.mod file.
+func goModHash(data []byte) (string, error) {
+	return dirhash.Hash1([]string{modFile}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// sumdbOps implements the external operations of the checksum database client.
+// The latest signed tree and the downloaded records and tiles are kept in the xk6 cache,
+// so the consistency of the database is also checked across invocations.
+type sumdbOps struct {
+	ctx  context.Context //nolint:containedctx
+	key  string
+	base string
+	dir  string
+}
+
+func (o *sumdbOps) ReadRemote(path string) ([]byte, error) {
+	resp, err := sumdbGet(o.ctx, o.base, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s, url: %s", errHTTP, resp.Status, o.base+path)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (o *sumdbOps) ReadConfig(file string) ([]byte, error) {
+	if file == sumdbKeyConfig {
+		return []byte(o.key), nil
+	}
+
+	sumdbMu.Lock()
+	defer sumdbMu.Unlock()
+
+	return o.readConfig(file)
+}
+
+func (o *sumdbOps) readConfig(file string) ([]byte, error) {
+	data, err := os.ReadFile(o.path(file)) //nolint:forbidigo
+	if errors.Is(err, fs.ErrNotExist) {
+		return []byte{}, nil
+	}
+
+	return data, err
+}
+
+func (o *sumdbOps) WriteConfig(file string, old, data []byte) error {
+	sumdbMu.Lock()
+	defer sumdbMu.Unlock()
+
+	current, err := o.readConfig(file)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(current, old) {
+		return sumdb.ErrWriteConflict
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path(file)), cacheDirPerm); err != nil { //nolint:forbidigo
+		return err
+	}
+
+	return writeFileAtomic(o.path(file), data)
+}
+
+func (o *sumdbOps) ReadCache(file string) ([]byte, error) {
+	return os.ReadFile(o.path(file)) //nolint:forbidigo
+}
+
+func (o *sumdbOps) WriteCache(file string, data []byte) {
+	err := os.MkdirAll(filepath.Dir(o.path(file)), cacheDirPerm) //nolint:forbidigo
+	if err == nil {
+		err = writeFileAtomic(o.path(file), data)
+	}
+
+	if err != nil {
+		slog.Debug("Failed to cache checksum database file", "file", file, "error", err)
+	}
+}
+
+func (o *sumdbOps) Log(msg string) {
+	slog.Debug(msg)
+}
+
+func (o *sumdbOps) SecurityError(msg string) {
+	slog.Error(msg)
+}
+
+func (o *sumdbOps) path(file string) string {
+	return filepath.Join(o.dir, filepath.FromSlash(file))
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const (
+	sumdbTestModule = "github.com/grafana/xk6-sumdb"
+	sumdbTestGoMod  = "module github.com/grafana/xk6-sumdb\n\nrequire go.k6.io/k6 v1.2.0\n"
+)
+
+// newSumDBProxy starts a Go proxy serving the go.mod of the test module and a checksum database
+// containing the hash of the original go.mod.
+func newSumDBProxy(t *testing.T, served string) {
+	t.Helper()
+
+	signer, verifier, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := goModHash([]byte(sumdbTestGoMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := sumdb.NewServer(sumdb.NewTestServer(signer, func(path, version string) ([]byte, error) {
+		if path != sumdbTestModule {
+			return nil, fmt.Errorf("%s: %w", path, errHTTP)
+		}
+
+		return fmt.Appendf(nil, "%s %s/go.mod %s\n", path, version, hash), nil
+	}))
+
+	sumdbSrv := httptest.NewServer(db)
+	t.Cleanup(sumdbSrv.Close)
+
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+sumdbTestModule+"/@v/v1.0.0.mod" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, served)
+	}))
+	t.Cleanup(proxySrv.Close)
+
+	t.Setenv("GOPROXY", proxySrv.URL)
+	t.Setenv("GOSUMDB", verifier+" "+sumdbSrv.URL)
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOENV", "off")
+	t.Setenv("XK6_CACHE_DIR", t.TempDir())
+}
+
+func TestGetModule_SumDB(t *testing.T) {
+	newSumDBProxy(t, sumdbTestGoMod)
+
+	mf, err := getModule(t.Context(), sumdbTestModule, "v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mf.Module.Mod.Path != sumdbTestModule {
+		t.Errorf("unexpected module: %s", mf.Module.Mod.Path)
+	}
+
+	// the records and the signed tree are cached
+	if _, err := getModule(t.Context(), sumdbTestModule, "v1.0.0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGetModule_SumDBMismatch(t *testing.T) {
+	const tampered = "module github.com/grafana/xk6-sumdb\n\nrequire go.k6.io/k6 v0.1.0\n"
+
+	newSumDBProxy(t, tampered)
+
+	_, err := getModule(t.Context(), sumdbTestModule, "v1.0.0")
+	if !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	t.Setenv("GONOSUMDB", "github.com/grafana")
+
+	if _, err := getModule(t.Context(), sumdbTestModule, "v1.0.0"); err != nil {
+		t.Errorf("expected no verification with GONOSUMDB, got %v", err)
+	}
+
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOFLAGS", "-mod=mod -insecure")
+
+	if _, err := getModule(t.Context(), sumdbTestModule, "v1.0.0"); err != nil {
+		t.Errorf("expected no verification with -insecure, got %v", err)
+	}
+}
+
+func TestParseGoSumDB(t *testing.T) {
+	t.Parallel()
+
+	name, _, direct, err := parseGoSumDB("")
+	if err != nil || name != defaultGoSumDB || direct != "https://sum.golang.org" {
+		t.Errorf("unexpected default: %s %s %v", name, direct, err)
+	}
+
+	name, _, direct, err = parseGoSumDB(goSumDBChina)
+	if err != nil || name != defaultGoSumDB || direct != "https://sum.golang.google.cn" {
+		t.Errorf("unexpected mirror: %s %s %v", name, direct, err)
+	}
+
+	if _, _, _, err := parseGoSumDB("sum.example.com"); !errors.Is(err, errInvalidGoSumDB) {
+		t.Errorf("expected invalid GOSUMDB error, got %v", err)
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"time"
+	"slices"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
@@ -20,9 +21,8 @@ import (
 )
 
 const (
-	defaultGoProxy = "https://proxy.golang.org"
-	k6BaseModule   = "go.k6.io/k6"
-	modFile        = "go.mod"
+	k6BaseModule = "go.k6.io/k6"
+	modFile      = "go.mod"
 )
 
 var errHTTP = errors.New("HTTP error")
@@ -35,25 +35,42 @@ type Change struct {
 	From string `json:"from,omitempty"`
 	// To is the version being replaced with.
 	To string `json:"to,omitempty"`
+	// Indirect is true if the extension does not require the module directly.
+	Indirect bool `json:"indirect,omitempty"`
+	// Reason explains why the change is needed.
+	Reason string `json:"reason,omitempty"`
+	// Line is the line of the require (or go, toolchain) directive in go.mod, zero if the module is not listed.
+	Line int `json:"line,omitempty"`
 }
 
 // Result represents the result of a synchronization operation.
 type Result struct {
+	// The k6 module path used for synchronization (go.k6.io/k6, a major version or a fork).
+	K6Module string `json:"k6_module,omitempty"`
 	// The k6 version used for synchronization.
 	K6Version string `json:"k6_version,omitempty"`
 	// Changes is a list of changes made to the module dependencies.
 	Changes []*Change `json:"changes,omitempty"`
+	// Secondary is a list of changes go mod tidy would still make after editing go.mod directly
+	// (see Options.Edit), such as requirements of the new module versions not satisfied by go.mod.
+	Secondary []*Change `json:"secondary,omitempty"`
 }
 
 // Sync synchronizes the versions of the module dependencies in the specified directory with k6.
 func Sync(ctx context.Context, dir string, opts *Options) (*Result, error) {
-	slog.Debug("Syncing dependencies with k6")
-
 	extModfile, err := loadModfile(dir)
 	if err != nil {
 		return nil, err
 	}
 
+	return syncModfile(ctx, dir, extModfile, opts)
+}
+
+// syncModfile synchronizes the extension in dir with k6, using the given go.mod of the extension,
+// which may differ from the go.mod in dir (e.g. edited in memory during a dry run).
+func syncModfile(ctx context.Context, dir string, extModfile *modfile.File, opts *Options) (*Result, error) {
+	slog.Debug("Syncing dependencies with k6")
+
 	k6ModulePath, k6Version, err := resolveK6Module(ctx, opts, extModfile)
 	if err != nil {
 		return nil, err
@@ -66,9 +83,21 @@ func Sync(ctx context.Context, dir string, opts *Options) (*Result, error) {
 		return nil, err
 	}
 
+	changes, err := graphChanges(ctx, dir, extModfile, k6ModulePath, k6Version, k6Modfile, opts.Edit)
+	if err != nil {
+		slog.Warn("Failed to load the module graph, only direct requirements are compared", "error", err)
+
+		changes = requireChanges(extModfile, k6Modfile, k6Version)
+	}
+
+	changes = append(directiveChanges(extModfile, k6Modfile, k6Version), changes...)
+
+	setLines(extModfile, changes)
+
 	result := &Result{
+		K6Module:  k6ModulePath,
 		K6Version: k6Version,
-		Changes:   diffRequires(extModfile, k6Modfile),
+		Changes:   changes,
 	}
 
 	if len(result.Changes) == 0 {
@@ -77,19 +106,40 @@ func Sync(ctx context.Context, dir string, opts *Options) (*Result, error) {
 		return result, nil
 	}
 
+	if opts.Edit {
+		// the edited files are only written without dry run, but the secondary changes are always reported
+		result.Secondary, err = editModFiles(ctx, dir, extModfile, result.Changes, !opts.DryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if opts.DryRun {
 		slog.Debug("Not saving changes, dry run")
 
 		return result, nil
 	}
 
-	patch := make([]string, 0, len(result.Changes)+1) // +1 for the "get" command
+	if opts.Edit {
+		return result, nil
+	}
+
+	if err := goGet(ctx, opts, result.Changes); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// goGet applies the changes with go get.
+func goGet(ctx context.Context, opts *Options, changes []*Change) error {
+	patch := make([]string, 0, len(changes)+1) // +1 for the "get" command
 
 	// Prepare the patch command to update go.mod
 	patch = append(patch, "get")
 
 	// Add each change to the patch command
-	for _, change := range result.Changes {
+	for _, change := range changes {
 		slog.Debug("Updating dependency", "module", change.Module, "from", change.From, "to", change.To)
 		patch = append(patch, fmt.Sprintf("%s@%s", change.Module, change.To))
 	}
@@ -101,12 +151,7 @@ func Sync(ctx context.Context, dir string, opts *Options) (*Result, error) {
 	cmd.Stdout = opts.Stdout
 	cmd.Stderr = opts.Stderr
 
-	err = cmd.Run()
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return cmd.Run()
 }
 
 // GetLatestVersion retrieves the latest version of the given module path from the Go proxy.
@@ -114,6 +159,47 @@ func GetLatestVersion(ctx context.Context, modulePath string) (string, error) {
 	return getLatestVersion(ctx, modulePath)
 }
 
+// ResolveVersion returns the canonical version of modulePath for the given version query.
+// The query can be a semver tag, "latest", a commit SHA, a branch name or a pseudo-version.
+// Canonical semver versions are returned as-is, without network access.
+func ResolveVersion(ctx context.Context, modulePath, query string) (string, error) {
+	if len(query) == 0 || query == "latest" {
+		return getLatestVersion(ctx, modulePath)
+	}
+
+	if semver.IsValid(query) && semver.Canonical(query) == query {
+		return query, nil
+	}
+
+	return probeVersionInfo(ctx, modulePath, query)
+}
+
+// ModuleRequirements returns the requirements listed in the go.mod file of the module version, read
+// from the Go proxy. The go.mod file of a module without version is read from the local directory modulePath.
+func ModuleRequirements(ctx context.Context, modulePath, version string) ([]module.Version, error) {
+	var (
+		mf  *modfile.File
+		err error
+	)
+
+	if len(version) == 0 {
+		mf, err = loadModfile(modulePath)
+	} else {
+		mf, err = getModule(ctx, modulePath, version)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]module.Version, 0, len(mf.Require))
+	for _, req := range mf.Require {
+		reqs = append(reqs, req.Mod)
+	}
+
+	return reqs, nil
+}
+
 // GetOverallLatestVersionFor returns the module path and version of the highest
 // published release of baseModule across all major versions. It probes baseModule,
 // baseModule/v2, baseModule/v3, … until a major is not found.
@@ -160,14 +246,24 @@ type versionInfo struct {
 // probeVersionInfo calls /@v/<version>.info for pkg.
 // On 200 it returns the canonical version string. Any non-200 response is an error.
 func probeVersionInfo(ctx context.Context, pkg, version string) (string, error) {
-	path, err := proxyPath(pkg, fmt.Sprintf("/@v/%s.info", version))
+	info, err := getVersionInfo(ctx, pkg, version)
 	if err != nil {
 		return "", err
 	}
 
+	return info.Version, nil
+}
+
+// getVersionInfo returns the response of the /@v/<version>.info endpoint for pkg.
+func getVersionInfo(ctx context.Context, pkg, version string) (*versionInfo, error) {
+	path, err := proxyPath(pkg, fmt.Sprintf("/@v/%s.info", version))
+	if err != nil {
+		return nil, err
+	}
+
 	resp, err := goProxyGet(ctx, path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	defer func() {
@@ -176,15 +272,15 @@ func probeVersionInfo(ctx context.Context, pkg, version string) (string, error)
 
 	if resp.StatusCode != http.StatusOK {
 		_, _ = io.Copy(io.Discard, resp.Body)
-		return "", fmt.Errorf("%w: %s, url: /%s/@v/%s.info", errHTTP, resp.Status, pkg, version)
+		return nil, fmt.Errorf("%w: %s, url: /%s/@v/%s.info", errHTTP, resp.Status, pkg, version)
 	}
 
 	var info versionInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return info.Version, nil
+	return &info, nil
 }
 
 // probeModuleVersionForBase asks the Go proxy which major-version of the given
@@ -208,29 +304,30 @@ func probeModuleVersionForBase(ctx context.Context, baseModule, version string)
 
 	const maxConsecutiveAbsent = 2
 
-	consecutiveAbsent := 0
+	// the value of a major version is true if it contains the version
+	contains := func(ctx context.Context, modPath string) (bool, bool) {
+		if _, ok := probeModuleForVersion(ctx, modPath, version); ok {
+			return true, true
+		}
 
-	for major := 2; ; major++ {
-		modPath := fmt.Sprintf("%s/v%d", baseModule, major)
+		if _, err := proxyLatestVersion(ctx, modPath); err != nil {
+			slog.Debug("Major version does not exist", "module", modPath)
 
-		if path, ok := probeModuleForVersion(ctx, modPath, version); ok {
-			return path, nil
+			return false, false
 		}
 
-		if _, err := getLatestVersion(ctx, modPath); err != nil {
-			consecutiveAbsent++
-			slog.Debug("Major version does not exist", "module", modPath, "consecutiveAbsent", consecutiveAbsent)
+		slog.Debug("Major version exists but does not contain SHA, trying next", "module", modPath)
 
-			if consecutiveAbsent >= maxConsecutiveAbsent {
-				slog.Debug("Stopping probe after consecutive absent majors", "base", baseModule)
-				break
-			}
-		} else {
-			consecutiveAbsent = 0
-			slog.Debug("Major version exists but does not contain SHA, trying next", "module", modPath)
-		}
+		return false, true
+	}
+
+	found := probeMajors(ctx, baseModule, maxConsecutiveAbsent, contains, func(ok bool) bool { return ok })
+	if len(found) != 0 && found[len(found)-1].value {
+		return found[len(found)-1].path, nil
 	}
 
+	slog.Debug("Stopping probe after consecutive absent majors", "base", baseModule)
+
 	return "", fmt.Errorf("could not find major version module for %q at version %q", baseModule, version)
 }
 
@@ -264,13 +361,46 @@ type ExtensionModule struct {
 // ResolveK6ModuleForExtensions determines which k6 module path and version to
 // use based on the dependencies declared by the given extensions. It reads each
 // extension's go.mod (locally when LocalPath is set, otherwise from the Go
-// proxy) and returns the first k6 require it finds. If none of the extensions
-// declare k6 as a dependency, it falls back to GetOverallLatestK6Version.
+// proxy) and picks the highest required k6 version, as minimal version selection would.
+// If the extensions require different k6 major versions, a *K6ConflictError is returned.
+// If none of the extensions declare k6 as a dependency, it falls back to GetOverallLatestK6Version.
 func ResolveK6ModuleForExtensions(
 	ctx context.Context, extensions []ExtensionModule,
 ) (modulePath, version string, err error) {
+	reqs := K6Requirements(ctx, extensions)
+
+	if len(reqs) == 0 {
+		slog.Debug("No extension declared k6, falling back to overall latest")
+
+		return getOverallLatestVersionFor(ctx, k6BaseModule)
+	}
+
+	if err := checkK6Majors(reqs); err != nil {
+		return "", "", err
+	}
+
+	modulePath, version = reqs[0].Module, reqs[0].Version
+
+	for _, req := range reqs[1:] {
+		if semver.Compare(req.Version, version) > 0 {
+			version = req.Version
+		}
+	}
+
+	if len(reqs) > 1 {
+		slog.Debug("Selected the highest k6 version required by the extensions", "k6module", modulePath, "version", version)
+	}
+
+	return modulePath, version, nil
+}
+
+// K6Requirements returns the k6 requirements declared by the given extensions.
+// Extensions whose go.mod cannot be read or which do not require k6 are skipped.
+func K6Requirements(ctx context.Context, extensions []ExtensionModule) []K6Requirement {
 	slog.Debug("Resolving k6 module from extension dependencies", "count", len(extensions))
 
+	reqs := make([]K6Requirement, 0, len(extensions))
+
 	for _, ext := range extensions {
 		slog.Debug("Checking extension go.mod for k6 dependency", "module", ext.Path)
 
@@ -280,18 +410,18 @@ func ResolveK6ModuleForExtensions(
 			continue
 		}
 
-		if k6path, k6ver, found := findK6Require(mf); found {
-			slog.Debug("Found k6 dependency in extension", "extension", ext.Path, "k6module", k6path, "k6version", k6ver)
-
-			return k6path, k6ver, nil
+		k6path, k6ver, found := findK6Require(mf)
+		if !found {
+			slog.Debug("Extension does not declare k6 as a dependency", "module", ext.Path)
+			continue
 		}
 
-		slog.Debug("Extension does not declare k6 as a dependency", "module", ext.Path)
-	}
+		slog.Debug("Found k6 dependency in extension", "extension", ext.Path, "k6module", k6path, "k6version", k6ver)
 
-	slog.Debug("No extension declared k6, falling back to overall latest")
+		reqs = append(reqs, K6Requirement{Extension: ext.Path, Module: k6path, Version: k6ver})
+	}
 
-	return getOverallLatestVersionFor(ctx, k6BaseModule)
+	return reqs
 }
 
 func resolveExtensionModfile(ctx context.Context, ext ExtensionModule) (*modfile.File, error) {
@@ -318,31 +448,76 @@ func resolveExtensionModfile(ctx context.Context, ext ExtensionModule) (*modfile
 	return getModule(ctx, ext.Path, ver)
 }
 
+// getOverallLatestVersionFor returns the latest version across the major versions of baseModule.
+// The latest version of each major version is picked again with the pre-release policy, so with
+// the auto policy a pre-release of a newer major version does not win over a release.
 func getOverallLatestVersionFor(ctx context.Context, baseModule string) (modulePath, version string, err error) {
-	bestVersion, err := getLatestVersion(ctx, baseModule)
+	baseVersion, err := getLatestVersion(ctx, baseModule)
 	if err != nil {
 		return "", "", err
 	}
 
-	bestPath := baseModule
+	paths := map[string]string{baseVersion: baseModule}
 
-	for major := 2; ; major++ {
-		modPath := fmt.Sprintf("%s/v%d", baseModule, major)
+	latest := func(ctx context.Context, path string) (string, bool) {
+		ver, err := getLatestVersion(ctx, path)
 
-		ver, probeErr := getLatestVersion(ctx, modPath)
-		if probeErr != nil {
-			// This major does not exist yet; stop probing.
-			break
-		}
+		// an error signals the major version does not exist; this is expected, not an error condition.
+		return ver, err == nil
+	}
+
+	for _, found := range probeMajors(ctx, baseModule, 1, latest, nil) {
+		paths[found.value] = found.path
+	}
+
+	bestVersion := pickLatest(slices.Collect(maps.Keys(paths)), prereleasePolicy(ctx))
+	if len(bestVersion) == 0 {
+		// pseudo-versions are not ordered by the policy
+		bestVersion = baseVersion
+	}
+
+	return paths[bestVersion], bestVersion, nil
+}
+
+// setLines sets the go.mod line of the changed requirements and directives.
+func setLines(mf *modfile.File, changes []*Change) {
+	lines := make(map[string]int)
 
-		if semver.Compare(ver, bestVersion) > 0 {
-			bestPath = modPath
-			bestVersion = ver
+	for _, req := range mf.Require {
+		if req.Syntax != nil {
+			lines[req.Mod.Path] = req.Syntax.Start.Line
 		}
 	}
 
-	// probeErr signals the major version does not exist; this is expected, not an error condition.
-	return bestPath, bestVersion, nil //nolint:nilerr
+	if mf.Go != nil && mf.Go.Syntax != nil {
+		lines[goDirective] = mf.Go.Syntax.Start.Line
+	}
+
+	if mf.Toolchain != nil && mf.Toolchain.Syntax != nil {
+		lines[toolchainDirective] = mf.Toolchain.Syntax.Start.Line
+	}
+
+	for _, change := range changes {
+		change.Line = lines[change.Module]
+	}
+}
+
+// requireChanges compares only the requirements listed in the go.mod files.
+func requireChanges(extModfile, k6Modfile *modfile.File, k6Version string) []*Change {
+	indirect := make(map[string]bool)
+
+	for _, req := range extModfile.Require {
+		indirect[req.Mod.Path] = req.Indirect
+	}
+
+	changes := diffRequires(extModfile, k6Modfile)
+
+	for _, change := range changes {
+		change.Indirect = indirect[change.Module]
+		change.Reason = fmt.Sprintf("%s required by go.mod, k6 %s requires %s", change.From, k6Version, change.To)
+	}
+
+	return changes
 }
 
 func diffRequires(extModfile, k6Modfile *modfile.File) []*Change {
@@ -369,9 +544,23 @@ func diffRequires(extModfile, k6Modfile *modfile.File) []*Change {
 // resolveK6Module determines the k6 module path and version to sync against.
 // It checks the extension's go.mod for any k6 major version (go.k6.io/k6, go.k6.io/k6/v2, etc.)
 // and falls back to opts.K6Version if set, or the overall latest version otherwise.
+// If opts.K6Repo is a k6 fork, the fork's module path (with or without /vN suffix) is used instead,
+// and its version is taken from the replace or require directive of the fork in go.mod.
 func resolveK6Module(ctx context.Context, opts *Options, mf *modfile.File) (modulePath, version string, err error) {
+	repo := opts.K6Repo
+	if len(repo) == 0 {
+		repo = k6BaseModule
+	}
+
+	base, pathMajor, _ := module.SplitPathVersion(repo)
+
 	if len(opts.K6Version) > 0 {
-		path, err := ResolveModuleForVersion(ctx, k6BaseModule, opts.K6Version)
+		// an explicit /vN suffix is trusted as-is
+		if len(pathMajor) != 0 {
+			return repo, opts.K6Version, nil
+		}
+
+		path, err := ResolveModuleForVersion(ctx, base, opts.K6Version)
 		if err != nil {
 			return "", "", err
 		}
@@ -379,13 +568,43 @@ func resolveK6Module(ctx context.Context, opts *Options, mf *modfile.File) (modu
 		return path, opts.K6Version, nil
 	}
 
-	if path, ver, found := findK6Require(mf); found {
+	if base == k6BaseModule {
+		if path, ver, found := findK6Require(mf); found {
+			return path, ver, nil
+		}
+	} else if path, ver, found := findForkRequire(mf, base); found {
 		return path, ver, nil
 	}
 
-	slog.Info("k6 not found in go.mod, using overall latest version")
+	if len(pathMajor) != 0 {
+		slog.Info("k6 not found in go.mod, using latest version", "module", repo)
 
-	return getOverallLatestVersionFor(ctx, k6BaseModule)
+		ver, err := getLatestVersion(ctx, repo)
+
+		return repo, ver, err
+	}
+
+	slog.Info("k6 not found in go.mod, using overall latest version", "module", base)
+
+	return getOverallLatestVersionFor(ctx, base)
+}
+
+// findForkRequire finds a k6 fork (any major version) in the given modfile. Forks are usually
+// used by replacing the k6 module, so the replace directives are checked before the requires.
+func findForkRequire(mf *modfile.File, forkBase string) (path, version string, found bool) {
+	for _, r := range mf.Replace {
+		if base, _, _ := module.SplitPathVersion(r.New.Path); base == forkBase && len(r.New.Version) != 0 {
+			return r.New.Path, r.New.Version, true
+		}
+	}
+
+	for _, r := range mf.Require {
+		if base, _, _ := module.SplitPathVersion(r.Mod.Path); base == forkBase {
+			return r.Mod.Path, r.Mod.Version, true
+		}
+	}
+
+	return "", "", false
 }
 
 // findK6Require finds a k6 module (any major version) in the given modfile.
@@ -428,16 +647,22 @@ func proxyPath(modulePath, suffix string) (string, error) {
 	return "/" + escaped + suffix, nil
 }
 
-func goproxy() string {
-	proxy := os.Getenv("GOPROXY") //nolint:forbidigo
-	if len(proxy) == 0 {
-		return defaultGoProxy
+func getModule(ctx context.Context, pkg string, version string) (*modfile.File, error) {
+	data, err := getModuleData(ctx, pkg, version)
+	if err != nil {
+		return nil, err
 	}
 
-	return proxy
+	mf, err := modfile.Parse(modFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return mf, nil
 }
 
-func getModule(ctx context.Context, pkg string, version string) (*modfile.File, error) {
+// getModuleData returns the go.mod file of the module version, verified against the checksum database.
+func getModuleData(ctx context.Context, pkg string, version string) ([]byte, error) {
 	path, err := proxyPath(pkg, fmt.Sprintf("/@v/%s.mod", version))
 	if err != nil {
 		return nil, err
@@ -461,15 +686,15 @@ func getModule(ctx context.Context, pkg string, version string) (*modfile.File,
 		return nil, err
 	}
 
-	mf, err := modfile.Parse(modFile, data, nil)
-	if err != nil {
+	if err := verifyGoMod(ctx, pkg, version, data); err != nil {
 		return nil, err
 	}
 
-	return mf, nil
+	return data, nil
 }
 
-func getLatestVersion(ctx context.Context, pkg string) (string, error) {
+// proxyLatestVersion returns the version of the module reported by the @latest endpoint of the Go proxy.
+func proxyLatestVersion(ctx context.Context, pkg string) (string, error) {
 	path, err := proxyPath(pkg, "/@latest")
 	if err != nil {
 		return "", err
@@ -499,60 +724,3 @@ func getLatestVersion(ctx context.Context, pkg string) (string, error) {
 
 	return latest.Version, nil
 }
-
-// goProxyGet fetches a URL from the Go module proxy and returns the response.
-// It retries on network errors and 5xx responses (up to 3 attempts, with 1s/2s backoff).
-// Responses with non-5xx status codes (including 404) are returned as-is; callers
-// are responsible for checking resp.StatusCode and closing resp.Body.
-func goProxyGet(ctx context.Context, url string) (*http.Response, error) {
-	fullURL := goproxy() + url
-
-	slog.Debug("Go proxy request", "url", fullURL)
-
-	const maxAttempts = 3
-
-	var lastErr error
-
-	for attempt := range maxAttempts {
-		if attempt > 0 {
-			delay := time.Duration(1<<(attempt-1)) * time.Second // 1s, 2s
-			t := time.NewTimer(delay)
-			select {
-			case <-t.C:
-			case <-ctx.Done():
-				t.Stop()
-				return nil, ctx.Err()
-			}
-		}
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
-		if err != nil {
-			return nil, err // malformed request, no point retrying
-		}
-
-		resp, err := http.DefaultClient.Do(req) //nolint:gosec
-		if err != nil {
-			slog.Debug("Go proxy request failed", "url", fullURL, "attempt", attempt+1, "error", err)
-			lastErr = err
-
-			continue
-		}
-
-		slog.Debug("Go proxy response", "url", fullURL, "status", resp.StatusCode) //nolint:gosec
-
-		// Retry on server-side errors; return everything else to the caller for status checking.
-		if resp.StatusCode >= 500 {
-			slog.Debug("Go proxy server error, will retry", //nolint:gosec
-				"url", fullURL, "attempt", attempt+1, "status", resp.StatusCode)
-			_, _ = io.Copy(io.Discard, resp.Body)
-			_ = resp.Body.Close()
-			lastErr = fmt.Errorf("%w: %s, url: %s", errHTTP, resp.Status, url)
-
-			continue
-		}
-
-		return resp, nil
-	}
-
-	return nil, lastErr
-}
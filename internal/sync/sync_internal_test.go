@@ -332,6 +332,44 @@ func TestResolveK6Module_V2FromGoMod(t *testing.T) {
 	}
 }
 
+func TestResolveK6Module_ForkFromReplace(t *testing.T) {
+	t.Parallel()
+
+	opts := &Options{K6Repo: "github.com/acme/k6"}
+	mf := &modfile.File{
+		Require: []*modfile.Require{
+			{Mod: mod("go.k6.io/k6", "v1.2.0")},
+		},
+		Replace: []*modfile.Replace{
+			{Old: mod("go.k6.io/k6", ""), New: mod("github.com/acme/k6", "v1.2.1-acme.1")},
+		},
+	}
+
+	path, version, err := resolveK6Module(t.Context(), opts, mf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "github.com/acme/k6" || version != "v1.2.1-acme.1" {
+		t.Errorf("expected the fork replacement, got %s@%s", path, version)
+	}
+}
+
+func TestResolveK6Module_ForkExplicitMajor(t *testing.T) {
+	t.Parallel()
+
+	opts := &Options{K6Repo: "github.com/acme/k6/v2", K6Version: "v2.1.0-acme.1"}
+
+	path, version, err := resolveK6Module(t.Context(), opts, &modfile.File{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "github.com/acme/k6/v2" || version != "v2.1.0-acme.1" {
+		t.Errorf("expected the fork major version, got %s@%s", path, version)
+	}
+}
+
 func TestGetOverallLatestK6Version_OnlyV1(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/go.k6.io/k6/@latest" {
@@ -816,3 +854,31 @@ func mod(path, version string) module.Version {
 		Version: version,
 	}
 }
+
+func TestModuleRequirements(t *testing.T) {
+	newModuleProxy(t, map[string]string{
+		"github.com/grafana/xk6-foo@v1.0.0": "require (\n\tgithub.com/grafana/sobek v1.1.0\n\tgo.k6.io/k6 v1.2.0\n)\n",
+	})
+
+	reqs, err := ModuleRequirements(t.Context(), "github.com/grafana/xk6-foo", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []module.Version{{Path: "github.com/grafana/sobek", Version: "v1.1.0"}, {Path: "go.k6.io/k6", Version: "v1.2.0"}}
+	if !reflect.DeepEqual(reqs, expected) {
+		t.Errorf("expected %v, got %v", expected, reqs)
+	}
+
+	// a local directory
+	dir := writeExtension(t, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.3.0\n")
+
+	reqs, err = ModuleRequirements(t.Context(), dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reqs, []module.Version{{Path: "go.k6.io/k6", Version: "v1.3.0"}}) {
+		t.Errorf("unexpected requirements of the local directory: %v", reqs)
+	}
+}
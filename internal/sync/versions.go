@@ -0,0 +1,323 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	semverv3 "github.com/Masterminds/semver/v3"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	errNoMatchingVersion   = errors.New("no version matches the constraint")
+	errNoCompatibleVersion = errors.New("no extension version is compatible")
+)
+
+// reConstraint matches the operators and wildcards of semver range constraints.
+var reConstraint = regexp.MustCompile(`[\^~<>=!*|, ]|(^|\.)[xX](\.|$)`)
+
+// IsConstraint reports whether the version query is a semver range constraint (e.g. ^1.2 or ~1.3)
+// rather than an exact version, "latest", a commit SHA or a branch name.
+func IsConstraint(query string) bool {
+	if !reConstraint.MatchString(query) {
+		return false
+	}
+
+	_, err := semverv3.NewConstraint(query)
+
+	return err == nil
+}
+
+// ResolveConstraint resolves a semver range constraint to the highest matching, not retracted
+// release of the module. If modulePath has no major version suffix, every major version
+// of the module is considered. The returned module path is the versioned module path of the
+// resolved version (e.g. github.com/grafana/xk6-sql/v2 for ^2.0).
+func ResolveConstraint(ctx context.Context, modulePath, constraint string) (string, string, error) {
+	con, err := semverv3.NewConstraint(constraint)
+	if err != nil {
+		return "", "", err
+	}
+
+	var bestPath, bestVersion string
+
+	var best *semverv3.Version
+
+	for _, path := range majorModulePaths(ctx, modulePath) {
+		path, version, err := highestMatching(ctx, path, con)
+		if err != nil {
+			return "", "", err
+		}
+
+		if len(version) == 0 {
+			continue
+		}
+
+		if ver := semverv3.MustParse(version); best == nil || ver.GreaterThan(best) {
+			best, bestPath, bestVersion = ver, path, version
+		}
+	}
+
+	if best == nil {
+		return "", "", fmt.Errorf("%w: %s@%s", errNoMatchingVersion, modulePath, constraint)
+	}
+
+	slog.Debug("Resolved version constraint", "module", bestPath, "constraint", constraint, "version", bestVersion)
+
+	return bestPath, bestVersion, nil
+}
+
+// majorModulePaths returns the module paths of the existing major versions of the module.
+// A module path that already has a major version suffix is returned as-is.
+func majorModulePaths(ctx context.Context, modulePath string) []string {
+	if _, pathMajor, ok := module.SplitPathVersion(modulePath); !ok || len(pathMajor) != 0 {
+		return []string{modulePath}
+	}
+
+	const maxConsecutiveAbsent = 2
+
+	exists := func(ctx context.Context, path string) (struct{}, bool) {
+		versions, err := listVersions(ctx, path)
+
+		return struct{}{}, err == nil && len(versions) != 0
+	}
+
+	paths := []string{modulePath}
+
+	for _, found := range probeMajors(ctx, modulePath, maxConsecutiveAbsent, exists, nil) {
+		paths = append(paths, found.path)
+	}
+
+	return paths
+}
+
+// highestMatching returns the highest, not retracted release of the module path matching the constraint.
+// The version is empty if no release matches.
+func highestMatching(ctx context.Context, modulePath string, con *semverv3.Constraints) (string, string, error) {
+	versions, err := listVersions(ctx, modulePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+
+	candidates := make([]string, 0, len(versions))
+
+	for _, version := range versions {
+		if !semver.IsValid(version) || semver.Canonical(version) != version {
+			continue
+		}
+
+		if module.CheckPathMajor(version, pathMajor) != nil {
+			continue
+		}
+
+		ver, err := semverv3.NewVersion(version)
+		if err != nil || !con.Check(ver) {
+			continue
+		}
+
+		candidates = append(candidates, version)
+	}
+
+	if len(candidates) == 0 {
+		return modulePath, "", nil
+	}
+
+	retracted, err := retractedVersions(ctx, modulePath)
+	if err != nil {
+		slog.Debug("Failed to get retracted versions", "module", modulePath, "error", err)
+	}
+
+	best := ""
+
+	for _, version := range candidates {
+		if isRetracted(version, retracted) {
+			slog.Debug("Skipping retracted version", "module", modulePath, "version", version)
+
+			continue
+		}
+
+		if len(best) == 0 || semver.Compare(version, best) > 0 {
+			best = version
+		}
+	}
+
+	return modulePath, best, nil
+}
+
+// listVersions returns the versions of the module known by the Go proxy (/@v/list).
+// A module unknown to the proxy has no versions.
+func listVersions(ctx context.Context, pkg string) ([]string, error) {
+	path, err := proxyPath(pkg, "/@v/list")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := goProxyGet(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s, url: /%s/@v/list", errHTTP, resp.Status, pkg)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(data)), nil
+}
+
+// retractedVersions returns the version intervals retracted by the go.mod of the latest version of the module.
+func retractedVersions(ctx context.Context, pkg string) ([]modfile.VersionInterval, error) {
+	latest, err := proxyLatestVersion(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	mf, err := getModule(ctx, pkg, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals := make([]modfile.VersionInterval, 0, len(mf.Retract))
+
+	for _, retract := range mf.Retract {
+		intervals = append(intervals, retract.VersionInterval)
+	}
+
+	return intervals, nil
+}
+
+func isRetracted(version string, intervals []modfile.VersionInterval) bool {
+	for _, interval := range intervals {
+		if semver.Compare(interval.Low, version) <= 0 && semver.Compare(version, interval.High) <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveCompatibleVersion returns the newest release of the extension module that works with the given k6 version.
+// The extension's published versions are walked from the newest, and the first one whose go.mod does not require
+// a newer k6 (or a different k6 major version) than k6Version is returned. Retracted versions are skipped,
+// pre-releases are considered according to the pre-release policy of the context.
+func ResolveCompatibleVersion(ctx context.Context, modulePath, k6Version string) (string, error) {
+	k6Path, err := ResolveModuleForVersion(ctx, k6BaseModule, k6Version)
+	if err != nil {
+		return "", err
+	}
+
+	versions, err := candidateVersions(ctx, modulePath)
+	if err != nil {
+		return "", err
+	}
+
+	var newest string
+
+	for _, version := range versions {
+		mf, err := getModule(ctx, modulePath, version)
+		if err != nil {
+			slog.Debug("Failed to read extension go.mod, skipping", "module", modulePath, "version", version, "error", err)
+
+			continue
+		}
+
+		reqPath, reqVersion, found := findK6Require(mf)
+		if !found || (reqPath == k6Path && semver.Compare(reqVersion, k6Version) <= 0) {
+			slog.Debug("Found compatible extension version",
+				"module", modulePath, "version", version, "k6", k6Version, "requires", reqVersion)
+
+			return version, nil
+		}
+
+		if len(newest) == 0 {
+			newest = fmt.Sprintf("%s requires %s@%s", version, reqPath, reqVersion)
+		}
+
+		slog.Debug("Extension version requires newer k6", "module", modulePath, "version", version, "requires", reqVersion)
+	}
+
+	if len(newest) == 0 {
+		return "", fmt.Errorf("%w with %s@%s: %s has no readable versions", errNoCompatibleVersion, k6Path, k6Version, modulePath)
+	}
+
+	return "", fmt.Errorf("%w with %s@%s: %s, newest %s", errNoCompatibleVersion, k6Path, k6Version, modulePath, newest)
+}
+
+// candidateVersions returns the not retracted versions of the module, newest first.
+// Pre-releases are included according to the pre-release policy of the context
+// (with the auto policy, only if the module has no releases). If the proxy does not list
+// any versions (e.g. only pseudo-versions exist), the latest version is returned.
+func candidateVersions(ctx context.Context, modulePath string) ([]string, error) {
+	versions, err := listVersions(ctx, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+
+	var releases, prereleases []string
+
+	for _, version := range versions {
+		if !semver.IsValid(version) || module.CheckPathMajor(version, pathMajor) != nil {
+			continue
+		}
+
+		if len(semver.Prerelease(version)) != 0 {
+			prereleases = append(prereleases, version)
+		} else {
+			releases = append(releases, version)
+		}
+	}
+
+	switch policy := prereleasePolicy(ctx); {
+	case policy == PrereleaseInclude:
+		releases = append(releases, prereleases...)
+	case policy == PrereleaseAuto && len(releases) == 0:
+		releases = prereleases
+	}
+
+	if len(releases) == 0 {
+		latest, err := getLatestVersion(ctx, modulePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{latest}, nil
+	}
+
+	retracted, err := retractedVersions(ctx, modulePath)
+	if err != nil {
+		slog.Debug("Failed to get retracted versions", "module", modulePath, "error", err)
+	}
+
+	releases = slices.DeleteFunc(releases, func(version string) bool { return isRetracted(version, retracted) })
+
+	semver.Sort(releases)
+	slices.Reverse(releases)
+
+	return releases, nil
+}
@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsConstraint(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"^1.2":          true,
+		"~1.3":          true,
+		">=1.2, <1.4":   true,
+		"1.2.x":         true,
+		"v1.2.3":        false,
+		"latest":        false,
+		"main":          false,
+		"abcdef123456":  false,
+		"":              false,
+		"^not-a-semver": false,
+	}
+
+	for query, expected := range tests {
+		if got := IsConstraint(query); got != expected {
+			t.Errorf("IsConstraint(%q) = %v, expected %v", query, got, expected)
+		}
+	}
+}
+
+func newVersionsProxy(t *testing.T) {
+	t.Helper()
+
+	const ext = "/github.com/grafana/xk6-foo"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ext + "/@v/list":
+			_, _ = fmt.Fprint(w, "v1.2.0\nv1.2.3\nv1.2.4\nv1.3.0\nv1.4.0-rc.1\n")
+		case ext + "/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v1.3.0"}`)
+		case ext + "/@v/v1.3.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-foo\n\nretract v1.2.4 // broken\n")
+		case ext + "/v2/@v/list":
+			_, _ = fmt.Fprint(w, "v2.0.0\nv2.1.0\n")
+		case ext + "/v2/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v2.1.0"}`)
+		case ext + "/v2/@v/v2.1.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-foo/v2\n")
+		case ext + "/v2/@v/v2.1.0.info":
+			_, _ = fmt.Fprint(w, `{"Version":"v2.1.0","Time":"2025-06-01T10:00:00Z"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+}
+
+func TestResolveConstraint(t *testing.T) {
+	newVersionsProxy(t)
+
+	tests := []struct {
+		constraint string
+		path       string
+		version    string
+	}{
+		{"~1.2", "github.com/grafana/xk6-foo", "v1.2.3"},
+		{"^1.2", "github.com/grafana/xk6-foo", "v1.3.0"},
+		{"^2", "github.com/grafana/xk6-foo/v2", "v2.1.0"},
+		{">=1.0", "github.com/grafana/xk6-foo/v2", "v2.1.0"},
+	}
+
+	for _, tt := range tests {
+		path, version, err := ResolveConstraint(t.Context(), "github.com/grafana/xk6-foo", tt.constraint)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.constraint, err)
+
+			continue
+		}
+
+		if path != tt.path || version != tt.version {
+			t.Errorf("%s: expected %s@%s, got %s@%s", tt.constraint, tt.path, tt.version, path, version)
+		}
+	}
+}
+
+func TestResolveConstraint_VersionedPath(t *testing.T) {
+	newVersionsProxy(t)
+
+	path, version, err := ResolveConstraint(t.Context(), "github.com/grafana/xk6-foo", "<2.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "github.com/grafana/xk6-foo" || version != "v1.3.0" {
+		t.Errorf("expected github.com/grafana/xk6-foo@v1.3.0, got %s@%s", path, version)
+	}
+
+	_, _, err = ResolveConstraint(t.Context(), "github.com/grafana/xk6-foo/v2", "^1.2")
+	if !errors.Is(err, errNoMatchingVersion) {
+		t.Errorf("expected errNoMatchingVersion, got %v", err)
+	}
+}
+
+func newCompatProxy(t *testing.T) {
+	t.Helper()
+
+	const ext = "/github.com/grafana/xk6-bar"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ext + "/@v/list":
+			_, _ = fmt.Fprint(w, "v0.1.0\nv0.2.0\nv0.3.0\nv0.4.0\nv0.5.0-rc.1\n")
+		case ext + "/@latest":
+			_, _ = fmt.Fprint(w, `{"version":"v0.4.0"}`)
+		case ext + "/@v/v0.4.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.4.0\n\nretract v0.2.0\n")
+		case ext + "/@v/v0.3.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.3.0\n")
+		case ext + "/@v/v0.2.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.1.0\n")
+		case ext + "/@v/v0.1.0.mod":
+			_, _ = fmt.Fprint(w, "module github.com/grafana/xk6-bar\n\nrequire go.k6.io/k6 v1.0.0\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("GOPROXY", srv.URL)
+}
+
+func TestResolveCompatibleVersion(t *testing.T) {
+	newCompatProxy(t)
+
+	tests := map[string]string{
+		"v1.4.0": "v0.4.0",
+		"v1.3.5": "v0.3.0",
+		// v0.2.0 is retracted
+		"v1.2.0": "v0.1.0",
+	}
+
+	for k6Version, expected := range tests {
+		version, err := ResolveCompatibleVersion(t.Context(), "github.com/grafana/xk6-bar", k6Version)
+		if err != nil {
+			t.Errorf("k6 %s: unexpected error: %v", k6Version, err)
+
+			continue
+		}
+
+		if version != expected {
+			t.Errorf("k6 %s: expected %s, got %s", k6Version, expected, version)
+		}
+	}
+}
+
+func TestResolveCompatibleVersion_None(t *testing.T) {
+	newCompatProxy(t)
+
+	_, err := ResolveCompatibleVersion(t.Context(), "github.com/grafana/xk6-bar", "v0.57.0")
+	if !errors.Is(err, errNoCompatibleVersion) {
+		t.Fatalf("expected errNoCompatibleVersion, got %v", err)
+	}
+
+	// a different k6 major version is never compatible
+	_, err = ResolveCompatibleVersion(t.Context(), "github.com/grafana/xk6-bar", "v2.0.0")
+	if !errors.Is(err, errNoCompatibleVersion) {
+		t.Fatalf("expected errNoCompatibleVersion, got %v", err)
+	}
+}